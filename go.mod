@@ -1,6 +1,6 @@
 module github.com/NateScarlet/cookiejar
 
-go 1.22
+go 1.23.0
 
 require (
 	github.com/NateScarlet/snapshot v0.6.0
@@ -0,0 +1,28 @@
+package test_util
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is a clock that only changes when Add called.
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Clock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -5,12 +5,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 )
 
 type AtomicOptions struct {
 	tmpSuffix            string
 	backupSuffix         string
+	sync                 bool
 	testForceRenameError error
 }
 
@@ -18,6 +20,7 @@ func newAtomicOptions(options ...AtomicOption) *AtomicOptions {
 	var opts = new(AtomicOptions)
 	opts.tmpSuffix = ".tmp"
 	opts.backupSuffix = "~"
+	opts.sync = true
 	for _, i := range options {
 		i(opts)
 	}
@@ -56,6 +59,9 @@ func AtomicSave(name string, write func(file *os.File) (err error), options ...A
 		if err != nil {
 			return
 		}
+		if opts.sync {
+			err = f.Sync()
+		}
 		return
 	}()
 	if err != nil {
@@ -91,13 +97,45 @@ func AtomicSave(name string, write func(file *os.File) (err error), options ...A
 	if opts.testForceRenameError != nil {
 		return opts.testForceRenameError
 	}
+	if opts.sync {
+		// persist tmp file and backup link before replace
+		err = SyncDir(filepath.Dir(name))
+		if err != nil {
+			return
+		}
+	}
 	err = os.Rename(nameTmp, name)
 	if err != nil {
 		return
 	}
+	if opts.sync {
+		err = SyncDir(filepath.Dir(name))
+	}
 	return
 }
 
+// SyncDir flushes directory entries to disk,
+// it is a no-op on windows which does not support it.
+func SyncDir(name string) (err error) {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// AtomicOptionSync controls whether to fsync the tmp file and directory
+// around the rename, defaults to true.
+func AtomicOptionSync(v bool) AtomicOption {
+	return func(opts *AtomicOptions) {
+		opts.sync = v
+	}
+}
+
 func AtomicOptionBackupSuffix(v string) AtomicOption {
 	return func(opts *AtomicOptions) {
 		opts.backupSuffix = v
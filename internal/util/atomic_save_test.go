@@ -53,6 +53,23 @@ func TestAtomicSave(t *testing.T) {
 		_, err = os.Stat(filepath.Join(dir, "file~"))
 		assert.True(t, os.IsNotExist(err))
 	})
+	t.Run("should update file without sync", func(t *testing.T) {
+		var dir = t.TempDir()
+
+		err := AtomicSave(filepath.Join(dir, "file"), func(file *os.File) (err error) {
+			_, err = file.Write([]byte("B"))
+			return
+		}, AtomicOptionSync(false))
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filepath.Join(dir, "file"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("B"), data)
+	})
+	t.Run("should sync dir", func(t *testing.T) {
+		assert.NoError(t, SyncDir(t.TempDir()))
+		assert.Error(t, SyncDir(filepath.Join(t.TempDir(), "not-exists")))
+	})
 	t.Run("should preserve old data if error during write", func(t *testing.T) {
 		var dir, err = os.MkdirTemp(os.TempDir(), "test-atomic-save-*")
 		require.NoError(t, err)
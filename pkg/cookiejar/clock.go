@@ -0,0 +1,21 @@
+package cookiejar
+
+import "time"
+
+// Clock provides current time to jar and repositories.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to Clock.
+type ClockFunc func() time.Time
+
+// Now implements Clock
+func (fn ClockFunc) Now() time.Time {
+	return fn()
+}
+
+// SystemClock returns clock that uses time.Now.
+func SystemClock() Clock {
+	return ClockFunc(time.Now)
+}
@@ -2,6 +2,7 @@ package cookiejar
 
 import (
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 )
@@ -11,23 +12,29 @@ import (
 // This struct type is not used outside of this package per se, but the exported
 // fields are those of RFC 6265.
 type Entry struct {
-	key        string
-	name       string
-	value      string
-	domain     string
-	path       string
-	sameSite   string
-	secure     bool
-	httpOnly   bool
-	persistent bool
-	hostOnly   bool
-	expires    time.Time
-	creation   time.Time
-	order      int
-}
-
-// ID returns the domain;path;name triple of e as an ID.
+	key          string
+	name         string
+	value        string
+	domain       string
+	path         string
+	sameSite     string
+	secure       bool
+	httpOnly     bool
+	persistent   bool
+	hostOnly     bool
+	partitionKey string
+	expires      time.Time
+	creation     time.Time
+	lastAccess   time.Time
+	order        int
+}
+
+// ID returns the key;domain;path;name quadruple of e as an ID,
+// partitioned entry has partition key as additional suffix.
 func (e *Entry) ID() string {
+	if e.partitionKey != "" {
+		return fmt.Sprintf("%s;%s;%s;%s;%s", e.key, e.domain, e.path, e.name, e.partitionKey)
+	}
 	return fmt.Sprintf("%s;%s;%s;%s", e.key, e.domain, e.path, e.name)
 }
 
@@ -38,6 +45,46 @@ func (e *Entry) shouldSend(https bool, host, path string) bool {
 	return e.domainMatch(host) && e.pathMatch(path) && (https || !e.secure)
 }
 
+// sameSiteAllows determines whether e's cookie qualifies to be included in a
+// cross-site request according to RFC 6265bis section 5.8.3.
+// laxAllowed reports whether the request is a top-level navigation with a
+// safe method. Cookies without a valid SameSite attribute are treated as Lax.
+func (e *Entry) sameSiteAllows(laxAllowed bool) bool {
+	switch e.sameSite {
+	case "SameSite=None":
+		return true
+	case "SameSite=Strict":
+		return false
+	default:
+		return laxAllowed
+	}
+}
+
+// sendBefore reports whether e should be sent before other according to
+// RFC 6265 section 5.4 point 2: by longest path and then by earliest creation
+// time.
+func (e *Entry) sendBefore(other Entry) bool {
+	if len(e.path) != len(other.path) {
+		return len(e.path) > len(other.path)
+	}
+	if !e.creation.Equal(other.creation) {
+		return e.creation.Before(other.creation)
+	}
+	return e.order < other.order
+}
+
+// evictBefore reports whether e should be evicted before other when cookie
+// count exceeds limit.
+func (e *Entry) evictBefore(other Entry) bool {
+	if !e.lastAccess.Equal(other.lastAccess) {
+		return e.lastAccess.Before(other.lastAccess)
+	}
+	if !e.creation.Equal(other.creation) {
+		return e.creation.Before(other.creation)
+	}
+	return e.order < other.order
+}
+
 // domainMatch implements "domain-match" of RFC 6265 section 5.1.3.
 func (e *Entry) domainMatch(host string) bool {
 	if e.domain == host {
@@ -105,6 +152,12 @@ func (obj Entry) HostOnly() bool {
 	return obj.hostOnly
 }
 
+// PartitionKey is the top-level site ("scheme://registrable-domain") a
+// partitioned cookie belongs to, empty for unpartitioned cookie.
+func (obj Entry) PartitionKey() string {
+	return obj.partitionKey
+}
+
 func (obj Entry) Expires() time.Time {
 	return obj.expires
 }
@@ -113,6 +166,59 @@ func (obj Entry) Creation() time.Time {
 	return obj.creation
 }
 
+// LastAccess is the time the entry last returned by the jar,
+// see RFC 6265 section 5.4 step 3. It is updated at most once a minute.
+func (obj Entry) LastAccess() time.Time {
+	return obj.lastAccess
+}
+
+// Cookie returns a http.Cookie with all attributes of the entry,
+// which recreates same entry when set to the same jar key.
+func (obj Entry) Cookie() *http.Cookie {
+	var c = &http.Cookie{
+		Name:        obj.name,
+		Value:       obj.value,
+		Path:        obj.path,
+		Secure:      obj.secure,
+		HttpOnly:    obj.httpOnly,
+		Partitioned: obj.partitionKey != "",
+	}
+	if !obj.hostOnly {
+		c.Domain = obj.domain
+	}
+	if obj.persistent {
+		c.Expires = obj.expires
+	}
+	switch obj.sameSite {
+	case "SameSite":
+		c.SameSite = http.SameSiteDefaultMode
+	case "SameSite=Strict":
+		c.SameSite = http.SameSiteStrictMode
+	case "SameSite=Lax":
+		c.SameSite = http.SameSiteLaxMode
+	case "SameSite=None":
+		c.SameSite = http.SameSiteNoneMode
+	}
+	return c
+}
+
+// EntryOption sets optional fields in EntryFromRepository.
+type EntryOption func(obj *Entry)
+
+// EntryOptionPartitionKey sets partition key of a partitioned cookie.
+func EntryOptionPartitionKey(v string) EntryOption {
+	return func(obj *Entry) {
+		obj.partitionKey = v
+	}
+}
+
+// EntryOptionLastAccess sets last access time, defaults to creation time.
+func EntryOptionLastAccess(v time.Time) EntryOption {
+	return func(obj *Entry) {
+		obj.lastAccess = v
+	}
+}
+
 // EntryFromRepository recreate object
 // DO NOT use this as constructor
 func EntryFromRepository(
@@ -129,6 +235,7 @@ func EntryFromRepository(
 	expires time.Time,
 	creation time.Time,
 	order int,
+	options ...EntryOption,
 ) (obj *Entry, err error) {
 	obj = &Entry{
 		key:        key,
@@ -143,8 +250,12 @@ func EntryFromRepository(
 		hostOnly:   hostOnly,
 		expires:    expires,
 		creation:   creation,
+		lastAccess: creation,
 		order:      order,
 	}
+	for _, i := range options {
+		i(obj)
+	}
 	return
 }
 
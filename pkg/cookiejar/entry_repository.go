@@ -2,6 +2,7 @@ package cookiejar
 
 import (
 	"context"
+	"time"
 )
 
 type EntryIterator interface {
@@ -21,3 +22,14 @@ type EntryRepository interface {
 	// Save should keep CreationTime and Order from previously saved entry.
 	Save(ctx context.Context, entry Entry) (err error)
 }
+
+// EntryLister is implemented by repositories that can enumerate all entries.
+type EntryLister interface {
+	FindAll(ctx context.Context) EntryIterator
+}
+
+// EntryToucher is implemented by repositories that can update last access
+// time of entries without saving whole entry.
+type EntryToucher interface {
+	Touch(ctx context.Context, id []string, t time.Time) (err error)
+}
@@ -3,6 +3,7 @@ package cookiejar
 import (
 	"context"
 	"sync"
+	"time"
 )
 
 type entryRepositoryInMemory struct {
@@ -49,6 +50,42 @@ func (r *entryRepositoryInMemory) Find(ctx context.Context, key string) EntryIte
 	})
 }
 
+// FindAll implements EntryLister
+func (r *entryRepositoryInMemory) FindAll(ctx context.Context) EntryIterator {
+	return EntryIteratorFunc(func(cb func(i Entry) (err error)) (err error) {
+		r.mu.Lock()
+		var entries = make([]Entry, 0, len(r.keyByID))
+		for _, m := range r.m {
+			for _, i := range m {
+				entries = append(entries, i)
+			}
+		}
+		r.mu.Unlock()
+		for _, i := range entries {
+			err = cb(i)
+			if err != nil {
+				return
+			}
+		}
+		return
+	})
+}
+
+// Touch implements EntryToucher
+func (r *entryRepositoryInMemory) Touch(ctx context.Context, id []string, t time.Time) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, i := range id {
+		var m = r.m[r.keyByID[i]]
+		if e, ok := m[i]; ok {
+			e.lastAccess = t
+			m[i] = e
+		}
+	}
+	return
+}
+
 // Save implements EntryRepository
 func (r *entryRepositoryInMemory) Save(ctx context.Context, e Entry) (err error) {
 	r.mu.Lock()
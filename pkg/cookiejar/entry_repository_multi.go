@@ -1,6 +1,10 @@
 package cookiejar
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
 
 // MultiEntryRepository write to all, read from first non-empty result.
 type MultiEntryRepository interface {
@@ -73,6 +77,49 @@ func (r multiEntryRepository) Find(ctx context.Context, key string) EntryIterato
 	})
 }
 
+// FindAll implements EntryLister,
+// entries of every target are merged, earlier target wins on same id.
+// targets that not implements EntryLister are skipped.
+func (r multiEntryRepository) FindAll(ctx context.Context) EntryIterator {
+	return EntryIteratorFunc(func(cb func(i Entry) (err error)) (err error) {
+		var supported bool
+		var seen = make(map[string]struct{})
+		for _, repo := range r.targets {
+			lister, ok := repo.(EntryLister)
+			if !ok {
+				continue
+			}
+			supported = true
+			err = lister.FindAll(ctx).ForEach(func(i Entry) (err error) {
+				var id = i.ID()
+				if _, ok := seen[id]; ok {
+					return
+				}
+				seen[id] = struct{}{}
+				return cb(i)
+			})
+			if err != nil {
+				return
+			}
+		}
+		if !supported {
+			err = errors.New("cookiejar: no target implements EntryLister")
+		}
+		return
+	})
+}
+
+// Touch implements EntryToucher,
+// targets that not implements EntryToucher are skipped.
+func (r multiEntryRepository) Touch(ctx context.Context, id []string, t time.Time) (err error) {
+	return r.parallel(func(repo EntryRepository) (err error) {
+		if toucher, ok := repo.(EntryToucher); ok {
+			return toucher.Touch(ctx, id, t)
+		}
+		return
+	})
+}
+
 // Save implements EntryRepository
 func (r multiEntryRepository) Save(ctx context.Context, entry Entry) (err error) {
 	return r.parallel(func(repo EntryRepository) (err error) {
@@ -80,6 +127,8 @@ func (r multiEntryRepository) Save(ctx context.Context, entry Entry) (err error)
 	})
 }
 
+// NewMultiEntryRepository returns a repository that also implements
+// EntryLister and EntryToucher, targets that not implements them are skipped.
 func NewMultiEntryRepository(targets ...EntryRepository) EntryRepository {
 	if len(targets) == 0 {
 		panic("empty targets")
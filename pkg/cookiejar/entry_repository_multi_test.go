@@ -17,7 +17,7 @@ func TestMultiEntryRepository(t *testing.T) {
 		if err != nil {
 			t.Error(err)
 		}
-		if len(repo1.m) != 1 || len(repo1.m) != 1 {
+		if len(repo1.m) != 1 || len(repo2.m) != 1 {
 			t.Error("should saved")
 		}
 	})
@@ -70,4 +70,36 @@ func TestMultiEntryRepository(t *testing.T) {
 			t.Error("should write back to first")
 		}
 	})
+	t.Run("should list from all", func(t *testing.T) {
+		var repo1 = NewInMemoryEntryRepository().(*entryRepositoryInMemory)
+		var repo2 = NewInMemoryEntryRepository().(*entryRepositoryInMemory)
+
+		var repo = NewMultiEntryRepository(repo1, repo2).(EntryLister)
+		err := repo1.Save(ctx, Entry{key: "a", name: "1", value: "first"})
+		if err != nil {
+			t.Error(err)
+		}
+		err = repo2.Save(ctx, Entry{key: "a", name: "1", value: "second"})
+		if err != nil {
+			t.Error(err)
+		}
+		err = repo2.Save(ctx, Entry{key: "b"})
+		if err != nil {
+			t.Error(err)
+		}
+		var matchCount int
+		err = repo.FindAll(ctx).ForEach(func(i Entry) (err error) {
+			matchCount++
+			if i.key == "a" && i.value != "first" {
+				t.Error("should prefer first")
+			}
+			return
+		})
+		if err != nil {
+			t.Error(err)
+		}
+		if matchCount != 2 {
+			t.Error("should match")
+		}
+	})
 }
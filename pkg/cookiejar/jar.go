@@ -8,6 +8,7 @@ package cookiejar
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"net/url"
@@ -50,6 +51,47 @@ type PublicSuffixList interface {
 
 type Jar interface {
 	http.CookieJar
+	// CookiesForRequest is like Cookies, but also enforces SameSite
+	// restrictions according to the request context.
+	CookiesForRequest(u *url.URL, req RequestContext) []*http.Cookie
+	// SetCookiesForRequest is like SetCookies, but stores partitioned
+	// cookies under the top-level site of the request context.
+	SetCookiesForRequest(u *url.URL, req RequestContext, cookies []*http.Cookie)
+	// Entries returns entries that Cookies will send to u, in RFC 6265
+	// send order. Last access time is not updated.
+	Entries(u *url.URL) (entries []Entry, err error)
+	// Prune removes expired entries and entries that not accessed within
+	// maxIdle. entry repository must implements EntryLister, and access time
+	// is only updated when it implements EntryToucher.
+	Prune(maxIdle time.Duration) (err error)
+	// All returns all unexpired entries ordered by jar key.
+	// entry repository must implements EntryLister.
+	All() (entries []Entry, err error)
+	// RemoveDomain removes entries of domain and its subdomains.
+	RemoveDomain(domain string) (err error)
+	// RemoveWhere removes entries that matches predicate.
+	// entry repository must implements EntryLister.
+	RemoveWhere(predicate func(e Entry) bool) (err error)
+	// Clear removes all entries.
+	// entry repository must implements EntryLister.
+	Clear() (err error)
+}
+
+// RequestContext describes the request cookies are retrieved for or received
+// from.
+type RequestContext struct {
+	// SiteForCookies is the URL of the document that initiated the request,
+	// nil means the request is same-site.
+	SiteForCookies *url.URL
+	// Method is the HTTP method of the request, defaults to GET.
+	Method string
+	// TopLevelNavigation reports whether the request navigates the
+	// top-level browsing context.
+	TopLevelNavigation bool
+	// TopLevelSite is the URL of the top-level document, used to partition
+	// cookies with the Partitioned attribute. nil means the request URL
+	// itself is the top-level document.
+	TopLevelSite *url.URL
 }
 
 // jar implements the http.CookieJar interface from the net/http package.
@@ -59,6 +101,10 @@ type jar struct {
 	entryRepo             EntryRepository
 	ctx                   context.Context
 	errorCB               func(err error)
+	evictCB               func(e Entry)
+	clock                 Clock
+	maxEntriesPerKey      int
+	maxEntries            int
 	creationIndexOffset   int
 	creationIndexOffsetMu sync.Mutex
 }
@@ -68,6 +114,10 @@ type Options struct {
 	publicSuffixList PublicSuffixList
 	entryRepository  EntryRepository
 	onError          func(err error)
+	onEvict          func(e Entry)
+	clock            Clock
+	maxEntriesPerKey int
+	maxEntries       int
 }
 
 // OptionPublicSuffixList is the public suffix list that determines whether
@@ -101,6 +151,42 @@ func OptionOnError(v func(err error)) Option {
 	}
 }
 
+// OptionMaxCookiesPerKey limits count of cookies stored under same jar key
+// (eTLD+1), zero means no limit.
+func OptionMaxCookiesPerKey(v int) Option {
+	return func(opts *Options) {
+		opts.maxEntriesPerKey = v
+	}
+}
+
+// OptionMaxCookies limits count of cookies stored in the jar,
+// zero means no limit.
+//
+// entry repository must implements EntryLister when this is set.
+func OptionMaxCookies(v int) Option {
+	return func(opts *Options) {
+		opts.maxEntries = v
+	}
+}
+
+// OptionOnEvict defines callback for entries evicted by cookie count limits.
+func OptionOnEvict(v func(e Entry)) Option {
+	return func(opts *Options) {
+		opts.onEvict = v
+	}
+}
+
+// OptionClock defines time source of the jar,
+// defaults to SystemClock().
+func OptionClock(v Clock) Option {
+	if v == nil {
+		panic("nil clock")
+	}
+	return func(opts *Options) {
+		opts.clock = v
+	}
+}
+
 func newOptions(options ...Option) *Options {
 	var opts = new(Options)
 	opts.onError = func(err error) {
@@ -108,6 +194,7 @@ func newOptions(options ...Option) *Options {
 	}
 	opts.entryRepository = NewInMemoryEntryRepository()
 	opts.publicSuffixList = publicsuffix.List
+	opts.clock = SystemClock()
 	for _, i := range options {
 		i(opts)
 	}
@@ -119,11 +206,18 @@ type Option func(opts *Options)
 // New returns a new cookie jar.
 func New(ctx context.Context, options ...Option) (Jar, error) {
 	var opts = newOptions(options...)
+	if _, ok := opts.entryRepository.(EntryLister); opts.maxEntries > 0 && !ok {
+		return nil, fmt.Errorf("cookiejar: OptionMaxCookies: %w", errNotLister)
+	}
 	jar := &jar{
-		ctx:       ctx,
-		psList:    opts.publicSuffixList,
-		entryRepo: opts.entryRepository,
-		errorCB:   opts.onError,
+		ctx:              ctx,
+		psList:           opts.publicSuffixList,
+		entryRepo:        opts.entryRepository,
+		errorCB:          opts.onError,
+		evictCB:          opts.onEvict,
+		clock:            opts.clock,
+		maxEntriesPerKey: opts.maxEntriesPerKey,
+		maxEntries:       opts.maxEntries,
 	}
 	return jar, nil
 }
@@ -146,13 +240,48 @@ func hasDotSuffix(s, suffix string) bool {
 //
 // It returns an empty slice if the URL's scheme is not HTTP or HTTPS.
 func (j *jar) Cookies(u *url.URL) (cookies []*http.Cookie) {
-	cookies, err := j.cookies(u, time.Now())
+	cookies, err := j.cookies(u, j.clock.Now())
+	j.onError(err)
+	return
+}
+
+// CookiesForRequest implements Jar.
+//
+// It returns an empty slice if the URL's scheme is not HTTP or HTTPS.
+func (j *jar) CookiesForRequest(u *url.URL, req RequestContext) (cookies []*http.Cookie) {
+	cookies, err := j.cookiesForRequest(u, &req, j.clock.Now())
 	j.onError(err)
 	return
 }
 
 // cookies is like Cookies but takes the current time as a parameter.
 func (j *jar) cookies(u *url.URL, now time.Time) (cookies []*http.Cookie, err error) {
+	return j.cookiesForRequest(u, nil, now)
+}
+
+// cookiesForRequest is like CookiesForRequest but takes the current time as a
+// parameter. nil req skips SameSite enforcement.
+func (j *jar) cookiesForRequest(u *url.URL, req *RequestContext, now time.Time) (cookies []*http.Cookie, err error) {
+	selected, err := j.entries(u, req, now)
+	if err != nil {
+		return
+	}
+	j.touch(selected, now)
+	for _, e := range selected {
+		cookies = append(cookies, &http.Cookie{Name: e.name, Value: e.value})
+	}
+
+	return
+}
+
+// Entries implements Jar.
+func (j *jar) Entries(u *url.URL) (entries []Entry, err error) {
+	return j.entries(u, nil, j.clock.Now())
+}
+
+// entries returns entries that should be sent to u in RFC 6265 order.
+// nil req skips SameSite enforcement.
+func (j *jar) entries(u *url.URL, req *RequestContext, now time.Time) (selected []Entry, err error) {
 	if u.Scheme != "http" && u.Scheme != "https" {
 		return
 	}
@@ -162,13 +291,22 @@ func (j *jar) cookies(u *url.URL, now time.Time) (cookies []*http.Cookie, err er
 	}
 	key := jarKey(host, j.psList)
 
+	var crossSite, laxAllowed bool
+	if req != nil {
+		crossSite = !j.isSameSite(u, req.SiteForCookies)
+		laxAllowed = req.TopLevelNavigation && isSafeMethod(req.Method)
+	}
+	partitionKey, err := j.partitionKey(u, req)
+	if err != nil {
+		return
+	}
+
 	https := u.Scheme == "https"
 	path := u.Path
 	if path == "" {
 		path = "/"
 	}
 
-	var selected []Entry
 	var deleteIDs []string
 	err = j.entryRepo.Find(j.ctx, key).ForEach(func(e Entry) (err error) {
 		if e.IsExpiredAt(now) {
@@ -178,6 +316,12 @@ func (j *jar) cookies(u *url.URL, now time.Time) (cookies []*http.Cookie, err er
 		if !e.shouldSend(https, host, path) {
 			return
 		}
+		if e.partitionKey != "" && e.partitionKey != partitionKey {
+			return
+		}
+		if crossSite && !e.sameSiteAllows(laxAllowed) {
+			return
+		}
 		selected = append(selected, e)
 		return
 	})
@@ -194,18 +338,8 @@ func (j *jar) cookies(u *url.URL, now time.Time) (cookies []*http.Cookie, err er
 	// sort according to RFC 6265 section 5.4 point 2: by longest
 	// path and then by earliest creation time.
 	sort.Slice(selected, func(i, j int) bool {
-		s := selected
-		if len(s[i].path) != len(s[j].path) {
-			return len(s[i].path) > len(s[j].path)
-		}
-		if !s[i].creation.Equal(s[j].creation) {
-			return s[i].creation.Before(s[j].creation)
-		}
-		return s[i].order < s[j].order
+		return selected[i].sendBefore(selected[j])
 	})
-	for _, e := range selected {
-		cookies = append(cookies, &http.Cookie{Name: e.name, Value: e.value})
-	}
 
 	return
 }
@@ -214,12 +348,26 @@ func (j *jar) cookies(u *url.URL, now time.Time) (cookies []*http.Cookie, err er
 //
 // It does nothing if the URL's scheme is not HTTP or HTTPS.
 func (j *jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
-	err := j.setCookies(u, cookies, time.Now())
+	err := j.setCookies(u, cookies, j.clock.Now())
+	j.onError(err)
+}
+
+// SetCookiesForRequest implements Jar.
+//
+// It does nothing if the URL's scheme is not HTTP or HTTPS.
+func (j *jar) SetCookiesForRequest(u *url.URL, req RequestContext, cookies []*http.Cookie) {
+	err := j.setCookiesForRequest(u, &req, cookies, j.clock.Now())
 	j.onError(err)
 }
 
 // setCookies is like SetCookies but takes the current time as parameter.
 func (j *jar) setCookies(u *url.URL, cookies []*http.Cookie, now time.Time) (err error) {
+	return j.setCookiesForRequest(u, nil, cookies, now)
+}
+
+// setCookiesForRequest is like SetCookiesForRequest but takes the current
+// time as parameter. nil req means u is the top-level document.
+func (j *jar) setCookiesForRequest(u *url.URL, req *RequestContext, cookies []*http.Cookie, now time.Time) (err error) {
 	if len(cookies) == 0 {
 		return
 	}
@@ -230,24 +378,41 @@ func (j *jar) setCookies(u *url.URL, cookies []*http.Cookie, now time.Time) (err
 	if err != nil {
 		return
 	}
+	partitionKey, err := j.partitionKey(u, req)
+	if err != nil {
+		return
+	}
 	key := jarKey(host, j.psList)
 	defPath := defaultPath(u.Path)
+	https := u.Scheme == "https"
 
 	j.creationIndexOffsetMu.Lock()
 	defer j.creationIndexOffsetMu.Unlock()
 	for index, cookie := range cookies {
 		err = func() (err error) {
+			err = checkNamePrefix(cookie, https)
+			if err != nil {
+				return
+			}
 			e, remove, err := j.newEntry(cookie, now, defPath, host)
 			if err != nil {
 				return
 			}
 			e.key = key
+			if cookie.Partitioned {
+				if !cookie.Secure {
+					err = errIllegalPartitioned
+					return
+				}
+				e.partitionKey = partitionKey
+			}
 			id := e.ID()
 			if remove {
 				err = j.entryRepo.Delete(j.ctx, id)
 				return
 			}
 			e.creation = now
+			e.lastAccess = now
 			e.order = j.creationIndexOffset + index
 			err = j.entryRepo.Save(j.ctx, e)
 			if err != nil {
@@ -261,9 +426,240 @@ func (j *jar) setCookies(u *url.URL, cookies []*http.Cookie, now time.Time) (err
 	}
 	j.creationIndexOffset += len(cookies)
 
+	err = j.evict(key, now)
+	return
+}
+
+// touchInterval is the precision of last access time, entries accessed
+// within it are not touched again, so frequent reads do not write the
+// repository every time.
+const touchInterval = time.Minute
+
+// touch updates last access time of entries according to RFC 6265 section 5.4
+// step 3.
+// It is best-effort: repositories that not implements EntryToucher keep
+// the previous time, and failure is reported to the error callback without
+// dropping matched entries.
+func (j *jar) touch(entries []Entry, now time.Time) {
+	toucher, ok := j.entryRepo.(EntryToucher)
+	if !ok {
+		return
+	}
+	var ids []string
+	for _, e := range entries {
+		if now.Sub(e.lastAccess) < touchInterval {
+			continue
+		}
+		ids = append(ids, e.ID())
+	}
+	if len(ids) == 0 {
+		return
+	}
+	j.onError(toucher.Touch(j.ctx, ids, now))
+}
+
+// Prune implements Jar.
+func (j *jar) Prune(maxIdle time.Duration) (err error) {
+	return j.prune(maxIdle, j.clock.Now())
+}
+
+// prune is like Prune but takes the current time as parameter.
+func (j *jar) prune(maxIdle time.Duration, now time.Time) (err error) {
+	return j.removeWhere(func(e Entry) bool {
+		return e.IsExpiredAt(now) || now.Sub(e.lastAccess) > maxIdle
+	})
+}
+
+// All implements Jar.
+func (j *jar) All() (entries []Entry, err error) {
+	return j.all(j.clock.Now())
+}
+
+// all is like All but takes the current time as parameter.
+func (j *jar) all(now time.Time) (entries []Entry, err error) {
+	lister, ok := j.entryRepo.(EntryLister)
+	if !ok {
+		return nil, errNotLister
+	}
+	err = lister.FindAll(j.ctx).ForEach(func(e Entry) (err error) {
+		if e.IsExpiredAt(now) {
+			return
+		}
+		entries = append(entries, e)
+		return
+	})
+	if err != nil {
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].key != entries[j].key {
+			return entries[i].key < entries[j].key
+		}
+		return entries[i].sendBefore(entries[j])
+	})
+	return
+}
+
+// RemoveWhere implements Jar.
+func (j *jar) RemoveWhere(predicate func(e Entry) bool) (err error) {
+	return j.removeWhere(predicate)
+}
+
+func (j *jar) removeWhere(predicate func(e Entry) bool) (err error) {
+	lister, ok := j.entryRepo.(EntryLister)
+	if !ok {
+		return errNotLister
+	}
+	return j.removeFrom(lister.FindAll(j.ctx), predicate)
+}
+
+// RemoveDomain implements Jar.
+func (j *jar) RemoveDomain(domain string) (err error) {
+	domain, err = canonicalHost(domain)
+	if err != nil {
+		return
+	}
+	var predicate = func(e Entry) bool {
+		return e.domain == domain || hasDotSuffix(e.domain, domain)
+	}
+	if !isIP(domain) && j.psList.PublicSuffix(domain) == domain {
+		// cookies of a public suffix spread over multiple jar keys.
+		return j.removeWhere(predicate)
+	}
+	return j.removeFrom(j.entryRepo.Find(j.ctx, jarKey(domain, j.psList)), predicate)
+}
+
+// Clear implements Jar.
+func (j *jar) Clear() (err error) {
+	return j.removeWhere(func(e Entry) bool { return true })
+}
+
+// removeFrom deletes entries from it that matches predicate.
+func (j *jar) removeFrom(it EntryIterator, predicate func(e Entry) bool) (err error) {
+	var ids []string
+	err = it.ForEach(func(e Entry) (err error) {
+		if predicate(e) {
+			ids = append(ids, e.ID())
+		}
+		return
+	})
+	if err != nil {
+		return
+	}
+	if len(ids) > 0 {
+		err = j.entryRepo.DeleteMany(j.ctx, ids)
+	}
+	return
+}
+
+// evict removes entries that exceed cookie count limits according to
+// RFC 6265 section 5.3 step 12.
+func (j *jar) evict(key string, now time.Time) (err error) {
+	if j.maxEntriesPerKey > 0 {
+		err = j.evictFrom(j.entryRepo.Find(j.ctx, key), j.maxEntriesPerKey, now)
+		if err != nil {
+			return
+		}
+	}
+	if j.maxEntries > 0 {
+		err = j.evictFrom(j.entryRepo.(EntryLister).FindAll(j.ctx), j.maxEntries, now)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// evictFrom removes expired entries from it, then removes entries that
+// least recently accessed until count of entries not exceeds limit.
+func (j *jar) evictFrom(it EntryIterator, limit int, now time.Time) (err error) {
+	var entries []Entry
+	var expiredCount int
+	err = it.ForEach(func(e Entry) (err error) {
+		if e.IsExpiredAt(now) {
+			expiredCount++
+		}
+		entries = append(entries, e)
+		return
+	})
+	if err != nil {
+		return
+	}
+	if len(entries) <= limit && expiredCount == 0 {
+		return
+	}
+	sort.Slice(entries, func(a, b int) bool {
+		var expiredA, expiredB = entries[a].IsExpiredAt(now), entries[b].IsExpiredAt(now)
+		if expiredA != expiredB {
+			return expiredA
+		}
+		return entries[a].evictBefore(entries[b])
+	})
+	var count = len(entries) - limit
+	if count < expiredCount {
+		count = expiredCount
+	}
+	var evicted = entries[:count]
+	var ids = make([]string, 0, len(evicted))
+	for _, e := range evicted {
+		ids = append(ids, e.ID())
+	}
+	err = j.entryRepo.DeleteMany(j.ctx, ids)
+	if err != nil {
+		return
+	}
+	if j.evictCB != nil {
+		for _, e := range evicted {
+			j.evictCB(e)
+		}
+	}
 	return
 }
 
+// isSameSite reports whether u is same-site with site according to
+// RFC 6265bis section 5.2, nil site is treated as same-site.
+func (j *jar) isSameSite(u *url.URL, site *url.URL) bool {
+	if site == nil {
+		return true
+	}
+	if u.Scheme != site.Scheme {
+		return false
+	}
+	host, err := canonicalHost(u.Host)
+	if err != nil {
+		return false
+	}
+	siteHost, err := canonicalHost(site.Host)
+	if err != nil {
+		return false
+	}
+	return jarKey(host, j.psList) == jarKey(siteHost, j.psList)
+}
+
+// partitionKey returns the key of the cookie partition for a request to u,
+// which is the scheme and registrable domain of the top-level site.
+func (j *jar) partitionKey(u *url.URL, req *RequestContext) (string, error) {
+	var site = u
+	if req != nil && req.TopLevelSite != nil {
+		site = req.TopLevelSite
+	}
+	host, err := canonicalHost(site.Host)
+	if err != nil {
+		return "", err
+	}
+	return site.Scheme + "://" + jarKey(host, j.psList), nil
+}
+
+// isSafeMethod reports whether method is a safe method defined in
+// RFC 7231 section 4.2.1, empty method means GET.
+func isSafeMethod(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
 // canonicalHost strips port from host if present and returns the canonicalized
 // host name.
 func canonicalHost(host string) (string, error) {
@@ -300,6 +696,17 @@ func hasPort(host string) bool {
 	return host[0] == '[' && strings.Contains(host, "]:")
 }
 
+// JarKey returns the key of entries that received from host,
+// which is the eTLD+1 of host according to psl.
+// Useful for repositories that import cookies from other sources.
+func JarKey(host string, psl PublicSuffixList) (string, error) {
+	host, err := canonicalHost(host)
+	if err != nil {
+		return "", err
+	}
+	return jarKey(host, psl), nil
+}
+
 // jarKey returns the key to use for a jar.
 func jarKey(host string, psl PublicSuffixList) string {
 	if isIP(host) {
@@ -398,15 +805,42 @@ func (j *jar) newEntry(c *http.Cookie, now time.Time, defPath, host string) (e E
 		e.sameSite = "SameSite=Strict"
 	case http.SameSiteLaxMode:
 		e.sameSite = "SameSite=Lax"
+	case http.SameSiteNoneMode:
+		e.sameSite = "SameSite=None"
 	}
 
 	return e, false, nil
 }
 
+// checkNamePrefix validates the "__Secure-" and "__Host-" cookie name prefixes
+// according to RFC 6265bis section 4.1.3. https reports whether c was received
+// from a secure origin.
+func checkNamePrefix(c *http.Cookie, https bool) error {
+	if hasPrefixFold(c.Name, "__Secure-") {
+		if !c.Secure || !https {
+			return errIllegalPrefix
+		}
+	}
+	if hasPrefixFold(c.Name, "__Host-") {
+		if !c.Secure || !https || c.Domain != "" || c.Path != "/" {
+			return errIllegalPrefix
+		}
+	}
+	return nil
+}
+
+// hasPrefixFold reports whether s begins with prefix, ASCII-case-insensitively.
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && ascii.EqualFold(s[:len(prefix)], prefix)
+}
+
 var (
-	errIllegalDomain   = errors.New("cookiejar: illegal cookie domain attribute")
-	errMalformedDomain = errors.New("cookiejar: malformed cookie domain attribute")
-	errNoHostname      = errors.New("cookiejar: no host name available (IP only)")
+	errIllegalDomain      = errors.New("cookiejar: illegal cookie domain attribute")
+	errIllegalPrefix      = errors.New("cookiejar: illegal cookie name prefix")
+	errIllegalPartitioned = errors.New("cookiejar: partitioned cookie without secure attribute")
+	errMalformedDomain    = errors.New("cookiejar: malformed cookie domain attribute")
+	errNoHostname         = errors.New("cookiejar: no host name available (IP only)")
+	errNotLister          = errors.New("cookiejar: entry repository not implements EntryLister")
 )
 
 // endOfTime is the time when session (non-persistent) cookies expire.
@@ -6,6 +6,7 @@ package cookiejar
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -1296,3 +1297,542 @@ func TestIssue19384(t *testing.T) {
 		}
 	}
 }
+
+var sameSiteTests = [...]struct {
+	description string
+	req         RequestContext
+	want        string
+}{
+	{
+		"Same-site request.",
+		RequestContext{SiteForCookies: mustParseURL("https://www.host.test")},
+		"default=1 lax=2 none=3 strict=4 unspecified=5",
+	},
+	{
+		"Same-site request from sibling host.",
+		RequestContext{SiteForCookies: mustParseURL("https://other.host.test/path")},
+		"default=1 lax=2 none=3 strict=4 unspecified=5",
+	},
+	{
+		"Nil site for cookies is same-site.",
+		RequestContext{Method: http.MethodPost},
+		"default=1 lax=2 none=3 strict=4 unspecified=5",
+	},
+	{
+		"Cross-site subresource request.",
+		RequestContext{SiteForCookies: mustParseURL("https://www.other.test")},
+		"none=3",
+	},
+	{
+		"Cross-scheme request is cross-site.",
+		RequestContext{SiteForCookies: mustParseURL("http://www.host.test")},
+		"none=3",
+	},
+	{
+		"Cross-site top-level navigation.",
+		RequestContext{
+			SiteForCookies:     mustParseURL("https://www.other.test"),
+			TopLevelNavigation: true,
+		},
+		"default=1 lax=2 none=3 unspecified=5",
+	},
+	{
+		"Cross-site top-level navigation with unsafe method.",
+		RequestContext{
+			SiteForCookies:     mustParseURL("https://www.other.test"),
+			Method:             http.MethodPost,
+			TopLevelNavigation: true,
+		},
+		"none=3",
+	},
+}
+
+func TestSameSite(t *testing.T) {
+	jar := newTestJar()
+	u := mustParseURL("https://www.host.test")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "default", Value: "1", SameSite: http.SameSiteDefaultMode},
+		{Name: "lax", Value: "2", SameSite: http.SameSiteLaxMode},
+		{Name: "none", Value: "3", SameSite: http.SameSiteNoneMode, Secure: true},
+		{Name: "strict", Value: "4", SameSite: http.SameSiteStrictMode},
+		{Name: "unspecified", Value: "5"},
+	})
+	for _, tc := range sameSiteTests {
+		var s []string
+		for _, c := range jar.CookiesForRequest(u, tc.req) {
+			s = append(s, c.Name+"="+c.Value)
+		}
+		sort.Strings(s)
+		if got := strings.Join(s, " "); got != tc.want {
+			t.Errorf("Test %q\ngot  %q\nwant %q", tc.description, got, tc.want)
+		}
+	}
+	if got := len(jar.Cookies(u)); got != 5 {
+		t.Errorf("Cookies should not enforce SameSite, got %d cookies", got)
+	}
+}
+
+var namePrefixTests = [...]struct {
+	fromURL string
+	cookie  string
+	wantErr error
+}{
+	{"https://www.host.test", "__Secure-A=a; secure", nil},
+	{"https://www.host.test", "__Secure-A=a; secure; domain=host.test; path=/", nil},
+	{"https://www.host.test", "__Secure-A=a", errIllegalPrefix},
+	{"http://www.host.test", "__Secure-A=a; secure", errIllegalPrefix},
+	{"https://www.host.test", "__secure-A=a", errIllegalPrefix},
+	{"https://www.host.test", "__Host-A=a; secure; path=/", nil},
+	{"https://www.host.test", "__Host-A=a; path=/", errIllegalPrefix},
+	{"http://www.host.test", "__Host-A=a; secure; path=/", errIllegalPrefix},
+	{"https://www.host.test", "__Host-A=a; secure", errIllegalPrefix},
+	{"https://www.host.test", "__Host-A=a; secure; path=/foo", errIllegalPrefix},
+	{"https://www.host.test", "__Host-A=a; secure; path=/; domain=www.host.test", errIllegalPrefix},
+	{"https://www.host.test", "__HOST-A=a; secure; path=/; domain=www.host.test", errIllegalPrefix},
+	{"http://www.host.test", "_Host-A=a", nil},
+}
+
+func TestNamePrefix(t *testing.T) {
+	for _, tc := range namePrefixTests {
+		jar := newTestJar()
+		cookies := (&http.Response{Header: http.Header{"Set-Cookie": {tc.cookie}}}).Cookies()
+		err := jar.setCookies(mustParseURL(tc.fromURL), cookies, tNow)
+		if err != tc.wantErr {
+			t.Errorf("%q/%q: got %v error, want %v", tc.fromURL, tc.cookie, err, tc.wantErr)
+			continue
+		}
+		got, err := jar.cookies(mustParseURL(tc.fromURL), tNow)
+		if err != nil {
+			t.Error(err)
+		}
+		if want := tc.wantErr == nil; (len(got) == 1) != want {
+			t.Errorf("%q/%q: got %d cookies, want stored %t", tc.fromURL, tc.cookie, len(got), want)
+		}
+	}
+}
+
+func TestPartitioned(t *testing.T) {
+	jar := newTestJar()
+	u := mustParseURL("https://widget.test/")
+	site1 := RequestContext{TopLevelSite: mustParseURL("https://www.site1.test/page")}
+	site2 := RequestContext{TopLevelSite: mustParseURL("https://www.site2.test/page")}
+	cookie := func(value string) []*http.Cookie {
+		return []*http.Cookie{{Name: "a", Value: value, Secure: true, Partitioned: true}}
+	}
+	if err := jar.setCookiesForRequest(u, &site1, cookie("1"), tNow); err != nil {
+		t.Fatal(err)
+	}
+	if err := jar.setCookiesForRequest(u, &site2, cookie("2"), tNow); err != nil {
+		t.Fatal(err)
+	}
+	if err := jar.setCookies(u, cookie("3"), tNow); err != nil {
+		t.Fatal(err)
+	}
+	if err := jar.setCookies(u, []*http.Cookie{{Name: "b", Value: "4"}}, tNow); err != nil {
+		t.Fatal(err)
+	}
+	if err := jar.setCookies(u, []*http.Cookie{{Name: "c", Partitioned: true}}, tNow); err != errIllegalPartitioned {
+		t.Errorf("got %v error, want %v", err, errIllegalPartitioned)
+	}
+
+	for _, tc := range []struct {
+		req  *RequestContext
+		want string
+	}{
+		{&site1, "a=1 b=4"},
+		{&site2, "a=2 b=4"},
+		{&RequestContext{TopLevelSite: mustParseURL("https://www.site3.test")}, "b=4"},
+		{nil, "a=3 b=4"},
+	} {
+		cookies, err := jar.cookiesForRequest(u, tc.req, tNow)
+		if err != nil {
+			t.Error(err)
+		}
+		var s []string
+		for _, c := range cookies {
+			s = append(s, c.Name+"="+c.Value)
+		}
+		sort.Strings(s)
+		if got := strings.Join(s, " "); got != tc.want {
+			t.Errorf("%+v: got %q, want %q", tc.req, got, tc.want)
+		}
+	}
+}
+
+func TestEviction(t *testing.T) {
+	var evicted []string
+	o, err := New(
+		context.Background(),
+		OptionPublicSuffixList(testPSL{}),
+		OptionMaxCookiesPerKey(3),
+		OptionMaxCookies(4),
+		OptionOnEvict(func(e Entry) {
+			evicted = append(evicted, e.Name())
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jar := o.(*jar)
+	set := func(rawURL string, now time.Time, cookies ...string) {
+		var setCookies []*http.Cookie
+		for _, cs := range cookies {
+			setCookies = append(setCookies, (&http.Response{Header: http.Header{"Set-Cookie": {cs}}}).Cookies()...)
+		}
+		if err := jar.setCookies(mustParseURL(rawURL), setCookies, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	set("http://www.host.test", tNow, "a=1", "b=2; max-age=1", "c=3")
+	set("http://www.host.test", tNow.Add(2*time.Second), "d=4")
+	if got := strings.Join(evicted, " "); got != "b" {
+		t.Errorf("expired entry should be evicted first, got %q", got)
+	}
+	set("http://www.host.test", tNow.Add(3*time.Second), "e=5")
+	if got := strings.Join(evicted, " "); got != "b a" {
+		t.Errorf("oldest entry should be evicted, got %q", got)
+	}
+	set("http://www.other.test", tNow.Add(4*time.Second), "f=6", "g=7")
+	if got := strings.Join(evicted, " "); got != "b a c" {
+		t.Errorf("oldest entry should be evicted by total limit, got %q", got)
+	}
+
+	_, err = New(
+		context.Background(),
+		OptionEntryRepository(nonListerRepository{}),
+		OptionMaxCookies(1),
+	)
+	if err == nil {
+		t.Error("should require EntryLister")
+	}
+}
+
+func TestMultiEntryRepositoryJar(t *testing.T) {
+	var repoA = NewInMemoryEntryRepository()
+	var repoB = NewInMemoryEntryRepository()
+	newJar := func(repo EntryRepository, options ...Option) *jar {
+		o, err := New(
+			context.Background(),
+			append([]Option{OptionPublicSuffixList(testPSL{}), OptionEntryRepository(repo)}, options...)...,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return o.(*jar)
+	}
+	set := func(jar *jar, rawURL string, now time.Time, name, value string) {
+		if err := jar.setCookies(mustParseURL(rawURL), []*http.Cookie{{Name: name, Value: value}}, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := func(jar *jar) string {
+		entries, err := jar.All()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var s []string
+		for _, e := range entries {
+			s = append(s, e.Name())
+		}
+		sort.Strings(s)
+		return strings.Join(s, " ")
+	}
+	set(newJar(repoA), "http://www.host.test", tNow, "a", "1")
+	set(newJar(repoB), "http://www.other.test", tNow.Add(time.Second), "session", "secret")
+
+	var evicted []string
+	jar := newJar(
+		NewMultiEntryRepository(repoA, repoB),
+		OptionMaxCookies(2),
+		OptionOnEvict(func(e Entry) {
+			evicted = append(evicted, e.Name())
+		}),
+	)
+	if got := names(jar); got != "a session" {
+		t.Errorf("should list entries of every target, got %q", got)
+	}
+	set(jar, "http://www.host.test", tNow.Add(2*time.Second), "b", "2")
+	if got := strings.Join(evicted, " "); got != "a" {
+		t.Errorf("oldest entry should be evicted by total limit, got %q", got)
+	}
+	if got := names(jar); got != "b session" {
+		t.Errorf("got %q", got)
+	}
+	if err := jar.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(jar); got != "" {
+		t.Errorf("should clear every target, got %q", got)
+	}
+	if got := jar.Cookies(mustParseURL("http://www.other.test")); len(got) != 0 {
+		t.Errorf("should clear every target, got %v", got)
+	}
+}
+
+// nonListerRepository is a EntryRepository that not implements EntryLister.
+type nonListerRepository struct {
+	EntryRepository
+}
+
+func TestLastAccess(t *testing.T) {
+	jar := newTestJar()
+	u := mustParseURL("http://www.host.test")
+	if err := jar.setCookies(u, []*http.Cookie{{Name: "a", Value: "1"}}, tNow); err != nil {
+		t.Fatal(err)
+	}
+	if err := jar.setCookies(mustParseURL("http://www.other.test"), []*http.Cookie{{Name: "b", Value: "2"}}, tNow); err != nil {
+		t.Fatal(err)
+	}
+	lastAccess := func(key string) (ret time.Time) {
+		for _, e := range jar.entryRepo.(*entryRepositoryInMemory).m[key] {
+			ret = e.LastAccess()
+		}
+		return
+	}
+	if got := lastAccess("host.test"); !got.Equal(tNow) {
+		t.Errorf("last access should equal creation, got %v", got)
+	}
+	if _, err := jar.cookies(u, tNow.Add(30*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastAccess("host.test"); !got.Equal(tNow) {
+		t.Errorf("last access should not be updated within touch interval, got %v", got)
+	}
+	accessTime := tNow.Add(time.Hour)
+	if _, err := jar.cookies(u, accessTime); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastAccess("host.test"); !got.Equal(accessTime) {
+		t.Errorf("last access should be updated, got %v", got)
+	}
+
+	if err := jar.prune(30*time.Minute, accessTime.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(jar.entryRepo.(*entryRepositoryInMemory).m["other.test"]); got != 0 {
+		t.Errorf("unused entry should be pruned, got %d entries", got)
+	}
+	if got := len(jar.entryRepo.(*entryRepositoryInMemory).m["host.test"]); got != 1 {
+		t.Errorf("recently used entry should be kept, got %d entries", got)
+	}
+}
+
+// readOnlyEntryRepository hides EntryToucher and rejects writes.
+type readOnlyEntryRepository struct {
+	EntryRepository
+}
+
+var errReadOnly = errors.New("read only")
+
+func (r readOnlyEntryRepository) Save(ctx context.Context, e Entry) error {
+	return errReadOnly
+}
+
+func TestLastAccessReadOnly(t *testing.T) {
+	repo := NewInMemoryEntryRepository()
+	u := mustParseURL("http://www.host.test")
+	jar := newTestJar()
+	jar.entryRepo = repo
+	if err := jar.setCookies(u, []*http.Cookie{{Name: "a", Value: "1"}}, tNow); err != nil {
+		t.Fatal(err)
+	}
+	jar.entryRepo = readOnlyEntryRepository{repo}
+	cookies, err := jar.cookies(u, tNow.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 1 || cookies[0].Name != "a" {
+		t.Errorf("matched cookies should be returned, got %v", cookies)
+	}
+	for _, e := range repo.(*entryRepositoryInMemory).m["host.test"] {
+		if !e.LastAccess().Equal(tNow) {
+			t.Errorf("last access should not be updated, got %v", e.LastAccess())
+		}
+	}
+}
+
+// failingToucherRepository rejects touch.
+type failingToucherRepository struct {
+	EntryRepository
+}
+
+func (r failingToucherRepository) Touch(ctx context.Context, id []string, t time.Time) error {
+	return errReadOnly
+}
+
+func TestLastAccessTouchError(t *testing.T) {
+	var errs []error
+	o, err := New(
+		context.Background(),
+		OptionPublicSuffixList(testPSL{}),
+		OptionEntryRepository(failingToucherRepository{NewInMemoryEntryRepository()}),
+		OptionOnError(func(err error) {
+			errs = append(errs, err)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jar := o.(*jar)
+	u := mustParseURL("http://www.host.test")
+	if err := jar.setCookies(u, []*http.Cookie{{Name: "a", Value: "1"}}, tNow); err != nil {
+		t.Fatal(err)
+	}
+	jar.clock = ClockFunc(func() time.Time { return tNow.Add(time.Hour) })
+	cookies := jar.Cookies(u)
+	if len(cookies) != 1 || cookies[0].Name != "a" {
+		t.Errorf("matched cookies should be returned, got %v", cookies)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], errReadOnly) {
+		t.Errorf("touch error should be reported, got %v", errs)
+	}
+}
+
+func TestEntries(t *testing.T) {
+	jar := newTestJar()
+	u := mustParseURL("https://www.host.test/foo/bar")
+	jarTest{
+		"Entries.",
+		"https://www.host.test/foo/",
+		[]string{
+			"a=1; path=/",
+			"b=2; path=/foo; domain=host.test; secure; httponly; samesite=strict; " + expiresIn(100),
+			"c=3; samesite=lax; max-age=100",
+		},
+		"a=1 b=2 c=3",
+		nil,
+	}.run(t, jar)
+	entries, err := jar.entries(u, nil, tNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Cookie().String())
+	}
+	want := []string{
+		"b=2; Path=/foo; Domain=host.test; Expires=Tue, 01 Jan 2013 12:01:40 GMT; HttpOnly; Secure; SameSite=Strict",
+		"c=3; Path=/foo; Expires=Tue, 01 Jan 2013 12:01:40 GMT; SameSite=Lax",
+		"a=1; Path=/",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	jar2 := newTestJar()
+	var cookies []*http.Cookie
+	for _, e := range entries {
+		cookies = append(cookies, e.Cookie())
+	}
+	if err := jar2.setCookies(u, cookies, tNow); err != nil {
+		t.Fatal(err)
+	}
+	entries2, err := jar2.entries(u, nil, tNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range entries {
+		a, b := entries[i], entries2[i]
+		a.creation, a.lastAccess, a.order = b.creation, b.lastAccess, b.order
+		if a != b {
+			t.Errorf("round-trip #%d: got %+v, want %+v", i, b, a)
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	names := func(jar *jar) string {
+		entries, err := jar.all(tNow)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var s []string
+		for _, e := range entries {
+			s = append(s, e.Name())
+		}
+		return strings.Join(s, " ")
+	}
+	populate := func() *jar {
+		jar := newTestJar()
+		for _, tc := range []struct {
+			rawURL string
+			cookie string
+		}{
+			{"http://www.host.test", "a=1; domain=host.test"},
+			{"http://www.host.test", "b=2"},
+			{"http://sub.www.host.test", "c=3"},
+			{"http://other.host.test", "d=4"},
+			{"http://www.other.test", "e=5"},
+			{"http://www.bbc.co.uk", "f=6"},
+			{"http://www.host.test", "g=7; max-age=-1"},
+		} {
+			cookies := (&http.Response{Header: http.Header{"Set-Cookie": {tc.cookie}}}).Cookies()
+			if err := jar.setCookies(mustParseURL(tc.rawURL), cookies, tNow); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return jar
+	}
+
+	jar := populate()
+	if got, want := names(jar), "f a b c d e"; got != want {
+		t.Errorf("All: got %q, want %q", got, want)
+	}
+
+	for _, tc := range []struct {
+		domain string
+		want   string
+	}{
+		{"www.host.test", "f a d e"},
+		{"WWW.HOST.TEST.", "f a d e"},
+		{"host.test", "f e"},
+		{"test", "f"},
+		{"co.uk", "a b c d e"},
+		{"other.test", "f a b c d"},
+	} {
+		jar := populate()
+		if err := jar.RemoveDomain(tc.domain); err != nil {
+			t.Fatal(err)
+		}
+		if got := names(jar); got != tc.want {
+			t.Errorf("RemoveDomain(%q): got %q, want %q", tc.domain, got, tc.want)
+		}
+	}
+
+	jar = populate()
+	if err := jar.RemoveWhere(func(e Entry) bool { return e.HostOnly() }); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(jar); got != "a" {
+		t.Errorf("RemoveWhere: got %q, want %q", got, "a")
+	}
+
+	jar = populate()
+	if err := jar.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(jar); got != "" {
+		t.Errorf("Clear: got %q, want empty", got)
+	}
+}
+
+func TestClock(t *testing.T) {
+	now := tNow
+	o, err := New(
+		context.Background(),
+		OptionPublicSuffixList(testPSL{}),
+		OptionClock(ClockFunc(func() time.Time { return now })),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := mustParseURL("http://www.host.test")
+	o.SetCookies(u, []*http.Cookie{{Name: "a", Value: "1", Expires: tNow.Add(time.Hour)}})
+	if got := len(o.Cookies(u)); got != 1 {
+		t.Errorf("got %d cookies before expires, want 1", got)
+	}
+	now = tNow.Add(2 * time.Hour)
+	if got := len(o.Cookies(u)); got != 0 {
+		t.Errorf("got %d cookies after expires, want 0", got)
+	}
+}
@@ -0,0 +1,3 @@
+// Package cookiejar_bolt stores entries in a bbolt database,
+// with one bucket per jar key.
+package cookiejar_bolt
@@ -0,0 +1,291 @@
+package cookiejar_bolt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar_record"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// entriesBucket holds a nested bucket of id to entry for each jar key.
+	entriesBucket = []byte("entries")
+	// keysBucket maps id to jar key.
+	keysBucket = []byte("keys")
+)
+
+type EntryRepository interface {
+	cookiejar.EntryRepository
+	cookiejar.EntryLister
+	cookiejar.EntryToucher
+}
+
+type entryRepository struct {
+	db     *bolt.DB
+	bucket []byte
+}
+
+// root returns repository bucket, it always exists after NewEntryRepository.
+func (r *entryRepository) root(tx *bolt.Tx) *bolt.Bucket {
+	return tx.Bucket(r.bucket)
+}
+
+func forEachInBucket(key string, b *bolt.Bucket, cb func(i cookiejar.Entry) (err error)) (err error) {
+	if b == nil {
+		return
+	}
+	return b.ForEach(func(k, v []byte) (err error) {
+		var po cookiejar_record.Record
+		err = json.Unmarshal(v, &po)
+		if err != nil {
+			return
+		}
+		do, err := po.DomainObject(key)
+		if err != nil {
+			return
+		}
+		return cb(*do)
+	})
+}
+
+// view collects entries in a read transaction and calls cb after
+// transaction closed, so cb can write to the repository.
+func (r *entryRepository) view(fn func(tx *bolt.Tx, cb func(i cookiejar.Entry) (err error)) (err error), cb func(i cookiejar.Entry) (err error)) (err error) {
+	var entries []cookiejar.Entry
+	err = r.db.View(func(tx *bolt.Tx) (err error) {
+		return fn(tx, func(i cookiejar.Entry) (err error) {
+			entries = append(entries, i)
+			return
+		})
+	})
+	if err != nil {
+		return
+	}
+	for _, i := range entries {
+		err = cb(i)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Find implements EntryRepository
+func (r *entryRepository) Find(ctx context.Context, key string) cookiejar.EntryIterator {
+	return cookiejar.EntryIteratorFunc(func(cb func(i cookiejar.Entry) (err error)) (err error) {
+		defer func() {
+			if err != nil {
+				err = fmt.Errorf("cookiejar_bolt: entryRepository.Find('%s'): %w", key, err)
+			}
+		}()
+		return r.view(func(tx *bolt.Tx, cb func(i cookiejar.Entry) (err error)) (err error) {
+			return forEachInBucket(key, r.root(tx).Bucket(entriesBucket).Bucket([]byte(key)), cb)
+		}, cb)
+	})
+}
+
+// FindAll implements EntryLister
+func (r *entryRepository) FindAll(ctx context.Context) cookiejar.EntryIterator {
+	return cookiejar.EntryIteratorFunc(func(cb func(i cookiejar.Entry) (err error)) (err error) {
+		defer func() {
+			if err != nil {
+				err = fmt.Errorf("cookiejar_bolt: entryRepository.FindAll: %w", err)
+			}
+		}()
+		return r.view(func(tx *bolt.Tx, cb func(i cookiejar.Entry) (err error)) (err error) {
+			var entries = r.root(tx).Bucket(entriesBucket)
+			return entries.ForEachBucket(func(k []byte) (err error) {
+				return forEachInBucket(string(k), entries.Bucket(k), cb)
+			})
+		}, cb)
+	})
+}
+
+// Delete implements EntryRepository
+func (r *entryRepository) Delete(ctx context.Context, id string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_bolt: entryRepository.Delete('%s'): %w", id, err)
+		}
+	}()
+	return r.DeleteMany(ctx, []string{id})
+}
+
+// DeleteMany implements EntryRepository,
+// all entries are deleted in one transaction.
+func (r *entryRepository) DeleteMany(ctx context.Context, id []string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_bolt: entryRepository.DeleteMany(%s): %w", id, err)
+		}
+	}()
+	return r.db.Update(func(tx *bolt.Tx) (err error) {
+		var root = r.root(tx)
+		var keys = root.Bucket(keysBucket)
+		var entries = root.Bucket(entriesBucket)
+		for _, i := range id {
+			var key = keys.Get([]byte(i))
+			if key == nil {
+				continue
+			}
+			var b = entries.Bucket(key)
+			if b != nil {
+				err = b.Delete([]byte(i))
+				if err != nil {
+					return
+				}
+				if k, _ := b.Cursor().First(); k == nil {
+					err = entries.DeleteBucket(key)
+					if err != nil {
+						return
+					}
+				}
+			}
+			err = keys.Delete([]byte(i))
+			if err != nil {
+				return
+			}
+		}
+		return
+	})
+}
+
+// Touch implements EntryToucher
+func (r *entryRepository) Touch(ctx context.Context, id []string, t time.Time) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_bolt: entryRepository.Touch(%s): %w", id, err)
+		}
+	}()
+	return r.db.Update(func(tx *bolt.Tx) (err error) {
+		var root = r.root(tx)
+		var keys = root.Bucket(keysBucket)
+		var entries = root.Bucket(entriesBucket)
+		for _, i := range id {
+			var key = keys.Get([]byte(i))
+			if key == nil {
+				continue
+			}
+			var b = entries.Bucket(key)
+			if b == nil {
+				continue
+			}
+			var data = b.Get([]byte(i))
+			if data == nil {
+				continue
+			}
+			var po cookiejar_record.Record
+			err = json.Unmarshal(data, &po)
+			if err != nil {
+				return
+			}
+			po.LastAccess = t.UTC()
+			data, err = json.Marshal(po)
+			if err != nil {
+				return
+			}
+			err = b.Put([]byte(i), data)
+			if err != nil {
+				return
+			}
+		}
+		return
+	})
+}
+
+// Save implements EntryRepository,
+// creation and order of existing entry are kept in same transaction.
+func (r *entryRepository) Save(ctx context.Context, e cookiejar.Entry) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_bolt: entryRepository.Save: %w", err)
+		}
+	}()
+	var id = []byte(e.ID())
+	var po = cookiejar_record.New(e)
+	return r.db.Update(func(tx *bolt.Tx) (err error) {
+		var root = r.root(tx)
+		b, err := root.Bucket(entriesBucket).CreateBucketIfNotExists([]byte(e.Key()))
+		if err != nil {
+			return
+		}
+		if data := b.Get(id); data != nil {
+			var old cookiejar_record.Record
+			err = json.Unmarshal(data, &old)
+			if err != nil {
+				return
+			}
+			po.Creation = old.Creation
+			po.Order = old.Order
+		}
+		data, err := json.Marshal(po)
+		if err != nil {
+			return
+		}
+		err = b.Put(id, data)
+		if err != nil {
+			return
+		}
+		return root.Bucket(keysBucket).Put(id, []byte(e.Key()))
+	})
+}
+
+// Options are the options for creating a new EntryRepository.
+type Options struct {
+	bucket string
+}
+
+type Option func(opts *Options)
+
+// OptionBucket defines top level bucket name used by the repository,
+// defaults to `cookiejar`.
+func OptionBucket(v string) Option {
+	if v == "" {
+		panic("empty bucket name")
+	}
+	return func(opts *Options) {
+		opts.bucket = v
+	}
+}
+
+func newOptions(options ...Option) *Options {
+	var opts = new(Options)
+	opts.bucket = "cookiejar"
+	for _, i := range options {
+		i(opts)
+	}
+	return opts
+}
+
+// NewEntryRepository use db to store cookies, required buckets are created
+// before return. The caller is responsible for closing db.
+func NewEntryRepository(db *bolt.DB, options ...Option) (_ EntryRepository, err error) {
+	if db == nil {
+		panic("nil db")
+	}
+	var opts = newOptions(options...)
+	var r = &entryRepository{
+		db:     db,
+		bucket: []byte(opts.bucket),
+	}
+	err = db.Update(func(tx *bolt.Tx) (err error) {
+		root, err := tx.CreateBucketIfNotExists(r.bucket)
+		if err != nil {
+			return
+		}
+		_, err = root.CreateBucketIfNotExists(entriesBucket)
+		if err != nil {
+			return
+		}
+		_, err = root.CreateBucketIfNotExists(keysBucket)
+		return
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cookiejar_bolt: NewEntryRepository: %w", err)
+	}
+	return r, nil
+}
@@ -0,0 +1,155 @@
+package cookiejar_bolt
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NateScarlet/cookiejar/internal/test_util"
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestEntryRepository(t *testing.T) {
+	var ctx = context.Background()
+	url1, _ := url.Parse("http://example.com")
+	var useDatabase = func(t *testing.T) *bolt.DB {
+		db, err := bolt.Open(filepath.Join(t.TempDir(), "cookies.db"), 0600, nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	var useJar = func(t *testing.T, db *bolt.DB) (cookiejar.Jar, EntryRepository, *test_util.Clock) {
+		repo, err := NewEntryRepository(db)
+		require.NoError(t, err)
+		var clock = test_util.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo), cookiejar.OptionClock(clock))
+		require.NoError(t, err)
+		return jar, repo, clock
+	}
+
+	t.Run("should isolate buckets", func(t *testing.T) {
+		var db = useDatabase(t)
+		var jar1, _, _ = useJar(t, db)
+		repo2, err := NewEntryRepository(db, OptionBucket("other"))
+		require.NoError(t, err)
+		jar2, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo2))
+		require.NoError(t, err)
+		jar1.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		assert.Len(t, jar1.Cookies(url1), 1)
+		assert.Len(t, jar2.Cookies(url1), 0)
+		require.NoError(t, db.View(func(tx *bolt.Tx) (err error) {
+			assert.NotNil(t, tx.Bucket([]byte("cookiejar")))
+			assert.NotNil(t, tx.Bucket([]byte("other")))
+			return
+		}))
+	})
+
+	t.Run("should delete many across keys", func(t *testing.T) {
+		var db = useDatabase(t)
+		var jar, repo, _ = useJar(t, db)
+		url2, _ := url.Parse("http://example.org")
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}})
+		jar.SetCookies(url2, []*http.Cookie{{Name: "c", Value: "3"}})
+		require.NoError(t, repo.DeleteMany(ctx, []string{
+			"example.com;example.com;/;a",
+			"example.org;example.org;/;c",
+			"example.org;example.org;/;missing",
+		}))
+		assert.Len(t, jar.Cookies(url1), 1)
+		assert.Len(t, jar.Cookies(url2), 0)
+		require.NoError(t, db.View(func(tx *bolt.Tx) (err error) {
+			var root = tx.Bucket([]byte("cookiejar"))
+			assert.Nil(t, root.Bucket(entriesBucket).Bucket([]byte("example.org")))
+			assert.Equal(t, 1, root.Bucket(keysBucket).Stats().KeyN)
+			return
+		}))
+	})
+
+	t.Run("should able to delete", func(t *testing.T) {
+		var db = useDatabase(t)
+		var jar, _, clock = useJar(t, db)
+		jar.SetCookies(url1, []*http.Cookie{
+			{Name: "a", Value: "1", Path: "/", Expires: clock.Now().Add(time.Second)},
+		})
+		assert.Len(t, jar.Cookies(url1), 1)
+		clock.Add(time.Second + 1)
+		assert.Len(t, jar.Cookies(url1), 0)
+		require.NoError(t, db.View(func(tx *bolt.Tx) (err error) {
+			var root = tx.Bucket([]byte("cookiejar"))
+			assert.Nil(t, root.Bucket(entriesBucket).Bucket([]byte("example.com")))
+			assert.Equal(t, 0, root.Bucket(keysBucket).Stats().KeyN)
+			return
+		}))
+	})
+
+	t.Run("should keep creation and order", func(t *testing.T) {
+		var jar, repo, clock = useJar(t, useDatabase(t))
+		jar.SetCookies(url1, []*http.Cookie{
+			{Name: "a", Value: "1"},
+			{Name: "b", Value: "2"},
+		})
+		var created = clock.Now()
+		clock.Add(time.Minute)
+		jar.SetCookies(url1, []*http.Cookie{
+			{Name: "b", Value: "3", MaxAge: 3600},
+		})
+		var entries = make(map[string]cookiejar.Entry)
+		require.NoError(t, repo.Find(ctx, "example.com").ForEach(func(i cookiejar.Entry) (err error) {
+			entries[i.Name()] = i
+			return
+		}))
+		require.Len(t, entries, 2)
+		assert.Equal(t, "3", entries["b"].Value())
+		assert.Equal(t, created, entries["b"].Creation())
+		assert.Equal(t, 1, entries["b"].Order())
+		assert.Equal(t, clock.Now(), entries["b"].LastAccess())
+		assert.Equal(t, clock.Now().Add(time.Hour), entries["b"].Expires())
+	})
+
+	t.Run("should touch", func(t *testing.T) {
+		var jar, repo, clock = useJar(t, useDatabase(t))
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		clock.Add(time.Hour)
+		jar.Cookies(url1)
+		require.NoError(t, repo.Find(ctx, "example.com").ForEach(func(i cookiejar.Entry) (err error) {
+			assert.Equal(t, clock.Now(), i.LastAccess())
+			return
+		}))
+	})
+
+	t.Run("should list all", func(t *testing.T) {
+		var jar, _, _ = useJar(t, useDatabase(t))
+		url2, _ := url.Parse("http://example.org")
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		jar.SetCookies(url2, []*http.Cookie{{Name: "b", Value: "2"}})
+		all, err := jar.All()
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+		require.NoError(t, jar.RemoveDomain("example.com"))
+		all, err = jar.All()
+		require.NoError(t, err)
+		require.Len(t, all, 1)
+		assert.Equal(t, "b", all[0].Name())
+	})
+
+	t.Run("should persist", func(t *testing.T) {
+		var filename = filepath.Join(t.TempDir(), "cookies.db")
+		db, err := bolt.Open(filename, 0600, nil)
+		require.NoError(t, err)
+		var jar, _, _ = useJar(t, db)
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		require.NoError(t, db.Close())
+
+		db, err = bolt.Open(filename, 0600, nil)
+		require.NoError(t, err)
+		defer db.Close()
+		jar, _, _ = useJar(t, db)
+		assert.Len(t, jar.Cookies(url1), 1)
+	})
+}
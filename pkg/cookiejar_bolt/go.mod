@@ -0,0 +1,22 @@
+module github.com/NateScarlet/cookiejar/pkg/cookiejar_bolt
+
+go 1.23.0
+
+require (
+	github.com/NateScarlet/cookiejar v0.4.0
+	github.com/stretchr/testify v1.10.0
+	go.etcd.io/bbolt v1.4.3
+)
+
+require (
+	github.com/NateScarlet/snapshot v0.6.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/net v0.0.0-20220726230323-06994584191e // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// develop against the root module in this repository,
+// consumers use the required release.
+replace github.com/NateScarlet/cookiejar => ../..
@@ -0,0 +1,327 @@
+package cookiejar_chromium
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+	"golang.org/x/net/publicsuffix"
+)
+
+var endOfTime = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+// windowsEpochOffset is microseconds between 1601-01-01 and 1970-01-01.
+const windowsEpochOffset = 11644473600000000
+
+// hashPrefixVersion is the first database version that prefixes decrypted
+// value with SHA256 of host_key.
+const hashPrefixVersion = 24
+
+// Options are the options for reading Chromium cookies.
+type Options struct {
+	publicSuffixList cookiejar.PublicSuffixList
+	secret           []byte
+	clock            cookiejar.Clock
+}
+
+type Option func(opts *Options)
+
+// OptionPublicSuffixList is used to compute entry key from cookie domain,
+// should be same as the jar that uses the entries.
+//
+// defaults to golang.org/x/net/publicsuffix.List
+func OptionPublicSuffixList(v cookiejar.PublicSuffixList) Option {
+	if v == nil {
+		panic("nil public suffix list")
+	}
+	return func(opts *Options) {
+		opts.publicSuffixList = v
+	}
+}
+
+// OptionSecret defines "Chrome Safe Storage" (or "Chromium Safe Storage")
+// secret from the keyring, which is used to decrypt `v11` values.
+//
+// defaults to the Linux fallback password "peanuts".
+func OptionSecret(v []byte) Option {
+	return func(opts *Options) {
+		opts.secret = v
+	}
+}
+
+// OptionClock is used to skip expired entries on import,
+// defaults to cookiejar.SystemClock().
+func OptionClock(v cookiejar.Clock) Option {
+	if v == nil {
+		panic("nil clock")
+	}
+	return func(opts *Options) {
+		opts.clock = v
+	}
+}
+
+func newOptions(options ...Option) *Options {
+	var opts = new(Options)
+	opts.publicSuffixList = publicsuffix.List
+	opts.clock = cookiejar.SystemClock()
+	for _, i := range options {
+		i(opts)
+	}
+	return opts
+}
+
+// chromiumTime converts microseconds since 1601-01-01 UTC to time.
+func chromiumTime(v int64) time.Time {
+	return time.UnixMicro(v - windowsEpochOffset).UTC()
+}
+
+func sameSite(v int64) string {
+	switch v {
+	case 0:
+		return "SameSite=None"
+	case 1:
+		return "SameSite=Lax"
+	case 2:
+		return "SameSite=Strict"
+	}
+	return ""
+}
+
+// databaseVersion returns version recorded in `meta` table,
+// zero if not recorded.
+func databaseVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var tableCount int
+	err := db.QueryRowContext(ctx, "SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'meta'").Scan(&tableCount)
+	if err != nil || tableCount == 0 {
+		return 0, err
+	}
+	var v string
+	err = db.QueryRowContext(ctx, "SELECT value FROM meta WHERE key = 'version'").Scan(&v)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(v)
+}
+
+// column selects the first existing name, or fallback expression when none
+// exists. empty fallback means the column is required.
+type column struct {
+	names    []string
+	fallback string
+}
+
+// columns in order of row fields, older databases use different names or
+// lack some of them.
+var columns = []column{
+	{names: []string{"host_key"}},
+	{names: []string{"name"}},
+	{names: []string{"value"}},
+	{names: []string{"encrypted_value"}, fallback: "X''"},
+	{names: []string{"path"}},
+	{names: []string{"creation_utc"}},
+	{names: []string{"expires_utc"}},
+	{names: []string{"last_access_utc"}, fallback: "0"},
+	{names: []string{"is_secure", "secure"}, fallback: "0"},
+	{names: []string{"is_httponly", "httponly"}, fallback: "0"},
+	{names: []string{"is_persistent", "persistent"}, fallback: "expires_utc != 0"},
+	{names: []string{"samesite"}, fallback: "-1"},
+	{names: []string{"top_frame_site_key"}, fallback: "''"},
+}
+
+// selectColumns returns select list of `cookies` table by probing its schema.
+func selectColumns(ctx context.Context, db *sql.DB) (_ string, err error) {
+	rows, err := db.QueryContext(ctx, "PRAGMA table_info(cookies)")
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	var exists = make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid     int
+			name    string
+			typ     string
+			notNull bool
+			dflt    sql.NullString
+			pk      int
+		)
+		err = rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk)
+		if err != nil {
+			return
+		}
+		exists[name] = true
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	var ret = make([]string, 0, len(columns))
+	for _, c := range columns {
+		var expr = c.fallback
+		for _, name := range c.names {
+			if exists[name] {
+				expr = name
+				break
+			}
+		}
+		if expr == "" {
+			return "", fmt.Errorf("missing column '%s'", c.names[0])
+		}
+		ret = append(ret, expr)
+	}
+	return strings.Join(ret, ",\n\t"), nil
+}
+
+type row struct {
+	hostKey         string
+	name            string
+	value           string
+	encryptedValue  []byte
+	path            string
+	creationUTC     int64
+	expiresUTC      int64
+	lastAccessUTC   int64
+	isSecure        bool
+	isHTTPOnly      bool
+	isPersistent    bool
+	sameSite        int64
+	topFrameSiteKey string
+}
+
+func (r row) entry(version int, order int, opts *Options) (_ *cookiejar.Entry, err error) {
+	var value = r.value
+	if len(r.encryptedValue) > 0 {
+		var b []byte
+		b, err = decrypt(r.encryptedValue, opts.secret)
+		if err != nil {
+			return
+		}
+		if version >= hashPrefixVersion {
+			if len(b) < sha256.Size {
+				return nil, errors.New("decrypted value too short")
+			}
+			b = b[sha256.Size:]
+		}
+		value = string(b)
+	}
+	var domain = r.hostKey
+	var hostOnly = true
+	if strings.HasPrefix(domain, ".") {
+		domain = domain[1:]
+		hostOnly = false
+	}
+	key, err := cookiejar.JarKey(domain, opts.publicSuffixList)
+	if err != nil {
+		return
+	}
+	var persistent = r.isPersistent && r.expiresUTC != 0
+	var expires = endOfTime
+	if persistent {
+		expires = chromiumTime(r.expiresUTC)
+	}
+	var creation = chromiumTime(r.creationUTC)
+	var lastAccess = creation
+	if r.lastAccessUTC != 0 {
+		lastAccess = chromiumTime(r.lastAccessUTC)
+	}
+	return cookiejar.EntryFromRepository(
+		key,
+		r.name,
+		value,
+		domain,
+		r.path,
+		sameSite(r.sameSite),
+		r.isSecure,
+		r.isHTTPOnly,
+		persistent,
+		hostOnly,
+		expires,
+		creation,
+		order,
+		cookiejar.EntryOptionPartitionKey(r.topFrameSiteKey),
+		cookiejar.EntryOptionLastAccess(lastAccess),
+	)
+}
+
+// Read entries from `cookies` table of db, ordered by creation time.
+func Read(ctx context.Context, db *sql.DB, options ...Option) cookiejar.EntryIterator {
+	var opts = newOptions(options...)
+	return cookiejar.EntryIteratorFunc(func(cb func(i cookiejar.Entry) (err error)) (err error) {
+		defer func() {
+			if err != nil {
+				err = fmt.Errorf("cookiejar_chromium: Read: %w", err)
+			}
+		}()
+		version, err := databaseVersion(ctx, db)
+		if err != nil {
+			return
+		}
+		selectList, err := selectColumns(ctx, db)
+		if err != nil {
+			return
+		}
+		rows, err := db.QueryContext(ctx, `
+SELECT
+	`+selectList+`
+FROM cookies
+ORDER BY creation_utc
+`)
+		if err != nil {
+			return
+		}
+		defer rows.Close()
+		var order int
+		for rows.Next() {
+			var r row
+			err = rows.Scan(
+				&r.hostKey,
+				&r.name,
+				&r.value,
+				&r.encryptedValue,
+				&r.path,
+				&r.creationUTC,
+				&r.expiresUTC,
+				&r.lastAccessUTC,
+				&r.isSecure,
+				&r.isHTTPOnly,
+				&r.isPersistent,
+				&r.sameSite,
+				&r.topFrameSiteKey,
+			)
+			if err != nil {
+				return
+			}
+			e, err := r.entry(version, order, opts)
+			if err != nil {
+				return fmt.Errorf("cookie '%s' of '%s': %w", r.name, r.hostKey, err)
+			}
+			err = cb(*e)
+			if err != nil {
+				return err
+			}
+			order++
+		}
+		return rows.Err()
+	})
+}
+
+// Import entries from `cookies` table of db into repo.
+// Expired entries are skipped.
+func Import(ctx context.Context, db *sql.DB, repo cookiejar.EntryRepository, options ...Option) (err error) {
+	var now = newOptions(options...).clock.Now()
+	return Read(ctx, db, options...).ForEach(func(i cookiejar.Entry) (err error) {
+		if i.IsExpiredAt(now) {
+			return
+		}
+		return repo.Save(ctx, i)
+	})
+}
@@ -0,0 +1,272 @@
+package cookiejar_chromium
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NateScarlet/cookiejar/internal/test_util"
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+const schema = `
+CREATE TABLE meta(key LONGVARCHAR NOT NULL UNIQUE PRIMARY KEY, value LONGVARCHAR);
+CREATE TABLE cookies(
+	creation_utc INTEGER NOT NULL,
+	host_key TEXT NOT NULL,
+	top_frame_site_key TEXT NOT NULL,
+	name TEXT NOT NULL,
+	value TEXT NOT NULL,
+	encrypted_value BLOB NOT NULL,
+	path TEXT NOT NULL,
+	expires_utc INTEGER NOT NULL,
+	is_secure INTEGER NOT NULL,
+	is_httponly INTEGER NOT NULL,
+	last_access_utc INTEGER NOT NULL,
+	has_expires INTEGER NOT NULL,
+	is_persistent INTEGER NOT NULL,
+	priority INTEGER NOT NULL,
+	samesite INTEGER NOT NULL,
+	source_scheme INTEGER NOT NULL,
+	source_port INTEGER NOT NULL,
+	last_update_utc INTEGER NOT NULL
+);
+`
+
+func toChromiumTime(t time.Time) int64 {
+	return t.UnixMicro() + windowsEpochOffset
+}
+
+func useDatabase(t *testing.T, version int) *sql.DB {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "Cookies"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	_, err = db.Exec(schema)
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO meta(key, value) VALUES ('version', ?)", version)
+	require.NoError(t, err)
+	return db
+}
+
+type testCookie struct {
+	hostKey         string
+	topFrameSiteKey string
+	name            string
+	value           string
+	encryptedValue  []byte
+	path            string
+	creation        time.Time
+	expires         time.Time
+	isSecure        bool
+	isHTTPOnly      bool
+	sameSite        int
+}
+
+func insertCookie(t *testing.T, db *sql.DB, c testCookie) {
+	if c.encryptedValue == nil {
+		c.encryptedValue = []byte{}
+	}
+	var expiresUTC int64
+	if !c.expires.IsZero() {
+		expiresUTC = toChromiumTime(c.expires)
+	}
+	_, err := db.Exec(`
+INSERT INTO cookies VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1, ?, 2, 443, ?)
+`,
+		toChromiumTime(c.creation),
+		c.hostKey,
+		c.topFrameSiteKey,
+		c.name,
+		c.value,
+		c.encryptedValue,
+		c.path,
+		expiresUTC,
+		c.isSecure,
+		c.isHTTPOnly,
+		toChromiumTime(c.creation),
+		expiresUTC != 0,
+		expiresUTC != 0,
+		c.sameSite,
+		toChromiumTime(c.creation),
+	)
+	require.NoError(t, err)
+}
+
+func TestImport(t *testing.T) {
+	var ctx = context.Background()
+	var now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var clock = test_util.NewClock(now)
+	var readAll = func(t *testing.T, db *sql.DB, options ...Option) (ret []cookiejar.Entry) {
+		var repo = cookiejar.NewInMemoryEntryRepository()
+		require.NoError(t, Import(ctx, db, repo, append(options, OptionClock(clock))...))
+		require.NoError(t, repo.(cookiejar.EntryLister).FindAll(ctx).ForEach(func(i cookiejar.Entry) (err error) {
+			ret = append(ret, i)
+			return
+		}))
+		return
+	}
+
+	t.Run("should map attributes", func(t *testing.T) {
+		var db = useDatabase(t, 23)
+		insertCookie(t, db, testCookie{
+			hostKey:    "www.example.com",
+			name:       "host",
+			value:      "1",
+			path:       "/",
+			creation:   now.Add(-time.Hour),
+			isHTTPOnly: true,
+			sameSite:   -1,
+		})
+		insertCookie(t, db, testCookie{
+			hostKey:        ".example.com",
+			name:           "domain",
+			encryptedValue: encrypt(t, "v10", []byte("2"), fallbackPassword),
+			path:           "/path",
+			creation:       now.Add(-time.Minute),
+			expires:        now.Add(time.Hour),
+			isSecure:       true,
+			sameSite:       2,
+		})
+		insertCookie(t, db, testCookie{
+			hostKey:         "widget.example.net",
+			topFrameSiteKey: "https://example.org",
+			name:            "partitioned",
+			value:           "3",
+			path:            "/",
+			creation:        now,
+			expires:         now.Add(time.Hour),
+			isSecure:        true,
+			sameSite:        0,
+		})
+		insertCookie(t, db, testCookie{
+			hostKey:  "www.example.com",
+			name:     "expired",
+			value:    "4",
+			path:     "/",
+			creation: now.Add(-2 * time.Hour),
+			expires:  now.Add(-time.Hour),
+		})
+
+		var entries = readAll(t, db)
+		require.Len(t, entries, 3)
+		var m = make(map[string]cookiejar.Entry)
+		for _, i := range entries {
+			m[i.Name()] = i
+		}
+
+		var host = m["host"]
+		assert.Equal(t, "example.com", host.Key())
+		assert.Equal(t, "www.example.com", host.Domain())
+		assert.Equal(t, "1", host.Value())
+		assert.True(t, host.HostOnly())
+		assert.True(t, host.HttpOnly())
+		assert.False(t, host.Persistent())
+		assert.Equal(t, "", host.SameSite())
+		assert.Equal(t, now.Add(-time.Hour), host.Creation())
+
+		var domain = m["domain"]
+		assert.Equal(t, "example.com", domain.Domain())
+		assert.Equal(t, "2", domain.Value())
+		assert.Equal(t, "/path", domain.Path())
+		assert.False(t, domain.HostOnly())
+		assert.True(t, domain.Secure())
+		assert.True(t, domain.Persistent())
+		assert.Equal(t, now.Add(time.Hour), domain.Expires())
+		assert.Equal(t, "SameSite=Strict", domain.SameSite())
+
+		var partitioned = m["partitioned"]
+		assert.Equal(t, "example.net", partitioned.Key())
+		assert.Equal(t, "https://example.org", partitioned.PartitionKey())
+		assert.Equal(t, "SameSite=None", partitioned.SameSite())
+	})
+
+	t.Run("should strip host key hash", func(t *testing.T) {
+		var db = useDatabase(t, 24)
+		var hash = sha256.Sum256([]byte("www.example.com"))
+		insertCookie(t, db, testCookie{
+			hostKey:        "www.example.com",
+			name:           "a",
+			encryptedValue: encrypt(t, "v11", append(hash[:], "value"...), []byte("secret")),
+			path:           "/",
+			creation:       now,
+		})
+		var entries = readAll(t, db, OptionSecret([]byte("secret")))
+		require.Len(t, entries, 1)
+		assert.Equal(t, "value", entries[0].Value())
+	})
+
+	t.Run("should work with jar", func(t *testing.T) {
+		var db = useDatabase(t, 24)
+		insertCookie(t, db, testCookie{
+			hostKey:  ".example.com",
+			name:     "a",
+			value:    "1",
+			path:     "/",
+			creation: now,
+		})
+		var repo = cookiejar.NewInMemoryEntryRepository()
+		require.NoError(t, Import(ctx, db, repo, OptionClock(clock)))
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo), cookiejar.OptionClock(clock))
+		require.NoError(t, err)
+		u, _ := url.Parse("https://www.example.com")
+		assert.Len(t, jar.Cookies(u), 1)
+	})
+
+	t.Run("should read legacy schema", func(t *testing.T) {
+		db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "Cookies"))
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		_, err = db.Exec(`
+CREATE TABLE cookies(
+	creation_utc INTEGER NOT NULL UNIQUE PRIMARY KEY,
+	host_key TEXT NOT NULL,
+	name TEXT NOT NULL,
+	value TEXT NOT NULL,
+	path TEXT NOT NULL,
+	expires_utc INTEGER NOT NULL,
+	secure INTEGER NOT NULL,
+	httponly INTEGER NOT NULL,
+	last_access_utc INTEGER NOT NULL
+);
+`)
+		require.NoError(t, err)
+		_, err = db.Exec(
+			"INSERT INTO cookies VALUES (?, '.example.com', 'a', '1', '/', ?, 1, 1, ?), (?, 'example.com', 'b', '2', '/', 0, 0, 0, ?)",
+			toChromiumTime(now), toChromiumTime(now.Add(time.Hour)), toChromiumTime(now),
+			toChromiumTime(now.Add(time.Second)), toChromiumTime(now),
+		)
+		require.NoError(t, err)
+		var entries []cookiejar.Entry
+		require.NoError(t, Read(ctx, db).ForEach(func(i cookiejar.Entry) (err error) {
+			entries = append(entries, i)
+			return
+		}))
+		require.Len(t, entries, 2)
+		assert.Equal(t, "a", entries[0].Name())
+		assert.False(t, entries[0].HostOnly())
+		assert.True(t, entries[0].Secure())
+		assert.True(t, entries[0].HttpOnly())
+		assert.True(t, entries[0].Persistent())
+		assert.Equal(t, now.Add(time.Hour), entries[0].Expires())
+		assert.Equal(t, "", entries[0].SameSite())
+		assert.Equal(t, "b", entries[1].Name())
+		assert.False(t, entries[1].Persistent())
+	})
+
+	t.Run("should reject missing required column", func(t *testing.T) {
+		db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "Cookies"))
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		_, err = db.Exec("CREATE TABLE cookies(host_key TEXT, name TEXT)")
+		require.NoError(t, err)
+		err = Read(ctx, db).ForEach(func(i cookiejar.Entry) (err error) { return })
+		assert.ErrorContains(t, err, "missing column 'value'")
+	})
+}
@@ -0,0 +1,78 @@
+package cookiejar_chromium
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Linux fallback key derivation parameters, used by Chromium when no keyring
+// is available.
+// See https://source.chromium.org/chromium/chromium/src/+/main:components/os_crypt/sync/os_crypt_linux.cc
+var (
+	fallbackPassword = []byte("peanuts")
+	salt             = []byte("saltysalt")
+	iv               = bytes.Repeat([]byte{' '}, aes.BlockSize)
+)
+
+const (
+	iterations = 1
+	keyLength  = 16
+)
+
+var errUnsupportedEncryption = errors.New("unsupported encryption version")
+
+// deriveKey returns AES key from keyring secret.
+func deriveKey(secret []byte) []byte {
+	return pbkdf2.Key(secret, salt, iterations, keyLength, sha1.New)
+}
+
+// decrypt a `encrypted_value` that prefixed with `v10` or `v11`.
+// empty secret means keyring is not available.
+func decrypt(data []byte, secret []byte) (_ []byte, err error) {
+	if len(data) < 3 {
+		return nil, errUnsupportedEncryption
+	}
+	var key []byte
+	switch string(data[:3]) {
+	case "v10":
+		key = deriveKey(fallbackPassword)
+	case "v11":
+		if len(secret) == 0 {
+			secret = fallbackPassword
+		}
+		key = deriveKey(secret)
+	default:
+		return nil, fmt.Errorf("%w: '%s'", errUnsupportedEncryption, data[:3])
+	}
+	data = data[3:]
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid ciphertext length")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return
+	}
+	var plaintext = make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, data)
+	return unpad(plaintext)
+}
+
+// unpad removes PKCS#7 padding.
+func unpad(data []byte) ([]byte, error) {
+	var n = int(data[len(data)-1])
+	if n == 0 || n > aes.BlockSize || n > len(data) {
+		return nil, errors.New("invalid padding, wrong secret?")
+	}
+	for _, i := range data[len(data)-n:] {
+		if int(i) != n {
+			return nil, errors.New("invalid padding, wrong secret?")
+		}
+	}
+	return data[:len(data)-n], nil
+}
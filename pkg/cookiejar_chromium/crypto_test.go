@@ -0,0 +1,55 @@
+package cookiejar_chromium
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeriveKey(t *testing.T) {
+	// well-known key of Linux fallback password
+	assert.Equal(t, "fd621fe5a2b402539dfa147ca9272778", hex.EncodeToString(deriveKey(fallbackPassword)))
+}
+
+// encrypt is the reverse of decrypt.
+func encrypt(t *testing.T, prefix string, plaintext []byte, secret []byte) []byte {
+	block, err := aes.NewCipher(deriveKey(secret))
+	require.NoError(t, err)
+	var n = aes.BlockSize - len(plaintext)%aes.BlockSize
+	for i := 0; i < n; i++ {
+		plaintext = append(plaintext, byte(n))
+	}
+	var ret = make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ret, plaintext)
+	return append([]byte(prefix), ret...)
+}
+
+func TestDecrypt(t *testing.T) {
+	t.Run("v10", func(t *testing.T) {
+		got, err := decrypt(encrypt(t, "v10", []byte("value"), fallbackPassword), []byte("ignored"))
+		require.NoError(t, err)
+		assert.Equal(t, "value", string(got))
+	})
+	t.Run("v11 with secret", func(t *testing.T) {
+		got, err := decrypt(encrypt(t, "v11", []byte("value"), []byte("secret")), []byte("secret"))
+		require.NoError(t, err)
+		assert.Equal(t, "value", string(got))
+	})
+	t.Run("v11 without secret", func(t *testing.T) {
+		got, err := decrypt(encrypt(t, "v11", []byte("value"), fallbackPassword), nil)
+		require.NoError(t, err)
+		assert.Equal(t, "value", string(got))
+	})
+	t.Run("v11 with wrong secret", func(t *testing.T) {
+		_, err := decrypt(encrypt(t, "v11", []byte("value"), []byte("secret")), []byte("wrong"))
+		assert.Error(t, err)
+	})
+	t.Run("unsupported", func(t *testing.T) {
+		_, err := decrypt([]byte("v20abc"), nil)
+		assert.ErrorIs(t, err, errUnsupportedEncryption)
+	})
+}
@@ -0,0 +1,6 @@
+// Package cookiejar_chromium imports entries from the "Cookies" SQLite
+// database of a Chromium (or Chrome) profile on Linux.
+//
+// The caller opens the database with a database/sql SQLite driver of its
+// choice, it is recommended to open a copy since the browser locks the file.
+package cookiejar_chromium
@@ -0,0 +1,131 @@
+package cookiejar_file
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/NateScarlet/cookiejar/internal/util"
+)
+
+// baseSuffix is suffix of the gzip compressed base file, which is read
+// before the uncompressed file that receives appends.
+const baseSuffix = ".gz"
+
+func (r *entryRepository) baseFilename() string {
+	return r.filename + baseSuffix
+}
+
+// statOptional returns nil info if file not exists.
+func statOptional(name string) (info os.FileInfo, err error) {
+	info, err = os.Stat(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return
+}
+
+// sameOptionalFileState is sameFileState that allows nil info.
+func sameOptionalFileState(a, b os.FileInfo) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return sameFileState(a, b)
+}
+
+// forEachBase reads records in the base file.
+func (r *entryRepository) forEachBase(cb func(i entry) (err error)) (info os.FileInfo, t tail, err error) {
+	t.version = formatVersion
+	f, err := os.Open(r.baseFilename())
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	info, err = f.Stat()
+	if err != nil {
+		return
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return
+	}
+	defer zr.Close()
+	t, err = readRecords(zr, cb, r.onMalformedLine)
+	return
+}
+
+func (r *entryRepository) writeRecords(name string, records []entry, compress bool) (err error) {
+	return util.AtomicSave(name, func(f *os.File) (err error) {
+		err = f.Chmod(0600)
+		if err != nil {
+			return
+		}
+		var w io.Writer = f
+		var zw *gzip.Writer
+		if compress {
+			zw = gzip.NewWriter(f)
+			w = zw
+		}
+		var encoder = json.NewEncoder(w)
+		if len(records) > 0 {
+			// empty file is treated as current version, header is not needed.
+			err = encoder.Encode(header{Version: formatVersion})
+			if err != nil {
+				return
+			}
+		}
+		for _, i := range records {
+			err = encoder.Encode(i)
+			if err != nil {
+				return
+			}
+		}
+		if zw != nil {
+			return zw.Close()
+		}
+		return
+	}, r.atomicOptions()...)
+}
+
+// rewrite replaces content of the files with records.
+//
+// Records in the uncompressed file are applied again after the base file
+// when interrupted between writes, it is safe since base file is written
+// first and applying same records again results in same entries.
+// caller should hold the lock.
+func (r *entryRepository) rewrite(records []entry) (err error) {
+	baseInfo, err := statOptional(r.baseFilename())
+	if err != nil {
+		return
+	}
+	if !r.compression && baseInfo == nil {
+		return r.writeRecords(r.filename, records, false)
+	}
+	err = r.writeRecords(r.baseFilename(), records, true)
+	if err != nil {
+		return
+	}
+	if r.compression {
+		return r.writeRecords(r.filename, nil, false)
+	}
+	// convert back to uncompressed
+	err = r.writeRecords(r.filename, records, false)
+	if err != nil {
+		return
+	}
+	err = os.Remove(r.baseFilename())
+	if err != nil {
+		return
+	}
+	if r.durability.mode != durabilityNone {
+		err = util.SyncDir(filepath.Dir(r.filename))
+	}
+	return
+}
@@ -0,0 +1,154 @@
+package cookiejar_file
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompression(t *testing.T) {
+	var ctx = context.Background()
+	url1, _ := url.Parse("http://example.com")
+	var useJar = func(t *testing.T, filename string, options ...Option) (cookiejar.Jar, EntryRepository) {
+		var repo = NewEntryRepository(filename, options...)
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo))
+		require.NoError(t, err)
+		return jar, repo
+	}
+	var names = func(t *testing.T, repo EntryRepository) (ret []string) {
+		require.NoError(t, repo.FindAll(ctx).ForEach(func(i cookiejar.Entry) (err error) {
+			ret = append(ret, i.Name())
+			return
+		}))
+		return
+	}
+	var fileSize = func(t *testing.T, name string) int64 {
+		info, err := os.Stat(name)
+		require.NoError(t, err)
+		return info.Size()
+	}
+
+	t.Run("should compact into compressed file", func(t *testing.T) {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		var jar, repo = useJar(t, filename, OptionCompression())
+		for i := 0; i < 100; i++ {
+			jar.SetCookies(url1, []*http.Cookie{{Name: fmt.Sprintf("name-%d", i), Value: "value"}})
+		}
+		var plainSize = fileSize(t, filename)
+		require.NoError(t, repo.Compact())
+		assert.Equal(t, int64(0), fileSize(t, filename))
+		assert.Less(t, fileSize(t, filename+baseSuffix), plainSize/2)
+		stats, err := repo.Stats()
+		require.NoError(t, err)
+		assert.Equal(t, fileSize(t, filename+baseSuffix), stats.Bytes)
+
+		jar.SetCookies(url1, []*http.Cookie{{Name: "name-0", MaxAge: -1}})
+		assert.Greater(t, fileSize(t, filename), int64(0))
+		assert.Len(t, names(t, NewEntryRepository(filename)), 99)
+	})
+
+	t.Run("should convert back to uncompressed file", func(t *testing.T) {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		var jar, repo = useJar(t, filename, OptionCompression())
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		require.NoError(t, repo.Compact())
+		jar.SetCookies(url1, []*http.Cookie{{Name: "b", Value: "2"}})
+
+		repo = NewEntryRepository(filename)
+		require.NoError(t, repo.Compact())
+		_, err := os.Stat(filename + baseSuffix)
+		assert.True(t, os.IsNotExist(err))
+		assert.ElementsMatch(t, []string{"a", "b"}, names(t, NewEntryRepository(filename)))
+	})
+
+	t.Run("should keep entries when interrupted after base written", func(t *testing.T) {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		var jar, repo = useJar(t, filename, OptionCompression())
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}})
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", MaxAge: -1}})
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "3"}})
+		jar.SetCookies(url1, []*http.Cookie{{Name: "b", MaxAge: -1}})
+		tail, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		require.NoError(t, repo.Compact())
+		// simulate crash before the uncompressed file truncated.
+		require.NoError(t, os.WriteFile(filename, tail, 0600))
+
+		var jar2, _ = useJar(t, filename)
+		var cookies = jar2.Cookies(url1)
+		require.Len(t, cookies, 1)
+		assert.Equal(t, "a", cookies[0].Name)
+		assert.Equal(t, "3", cookies[0].Value)
+	})
+
+	t.Run("should repair", func(t *testing.T) {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		var jar, repo = useJar(t, filename, OptionCompression())
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		require.NoError(t, repo.Compact())
+		jar.SetCookies(url1, []*http.Cookie{{Name: "b", Value: "2"}})
+		f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0600)
+		require.NoError(t, err)
+		_, err = f.WriteString("{torn")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		require.NoError(t, repo.Repair())
+		assert.Equal(t, int64(0), fileSize(t, filename))
+		assert.ElementsMatch(t, []string{"a", "b"}, names(t, NewEntryRepository(filename)))
+	})
+
+	t.Run("should salvage corrupt base on repair", func(t *testing.T) {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		var jar, repo = useJar(t, filename, OptionCompression())
+		var cookies []*http.Cookie
+		for i := 0; i < 1000; i++ {
+			cookies = append(cookies, &http.Cookie{Name: fmt.Sprintf("c%d", i), Value: fmt.Sprintf("%x", sha256.Sum256([]byte{byte(i), byte(i >> 8)}))})
+		}
+		jar.SetCookies(url1, cookies)
+		require.NoError(t, repo.Compact())
+		var baseFilename = filename + baseSuffix
+		require.NoError(t, os.Truncate(baseFilename, fileSize(t, baseFilename)/2))
+
+		_, err := NewEntryRepository(filename).Stats()
+		assert.Error(t, err)
+
+		var reported []*MalformedLineError
+		repo = NewEntryRepository(filename, OptionCompression(), OptionOnMalformedLine(func(err *MalformedLineError) {
+			reported = append(reported, err)
+		}))
+		require.NoError(t, repo.Repair())
+		require.NotEmpty(t, reported)
+		assert.ErrorIs(t, reported[len(reported)-1], io.ErrUnexpectedEOF)
+		var salvaged = names(t, NewEntryRepository(filename))
+		assert.Greater(t, len(salvaged), 0)
+		assert.Less(t, len(salvaged), 1000)
+	})
+
+	t.Run("should drop base with corrupt header on repair", func(t *testing.T) {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		var jar, repo = useJar(t, filename, OptionCompression())
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		require.NoError(t, repo.Compact())
+		jar.SetCookies(url1, []*http.Cookie{{Name: "b", Value: "2"}})
+		require.NoError(t, os.WriteFile(filename+baseSuffix, []byte("not gzip"), 0600))
+
+		var count int
+		repo = NewEntryRepository(filename, OptionCompression(), OptionOnMalformedLine(func(err *MalformedLineError) {
+			count++
+		}))
+		require.NoError(t, repo.Repair())
+		assert.Equal(t, 1, count)
+		assert.Equal(t, []string{"b"}, names(t, NewEntryRepository(filename)))
+	})
+}
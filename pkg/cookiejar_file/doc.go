@@ -1,3 +1,10 @@
 // Package cookiejar_file implements entry repository with file in a append-only manner.
-// user can call EntryRepository.Compact() to reduce file size.
+// user can call EntryRepository.Compact() to reduce file size,
+// or use compaction options to compact automatically.
+//
+// File starts with a version header, files written by older versions are
+// migrated on read and rewritten in current format before next write.
+//
+// NewShardedEntryRepository stores each jar key in its own file inside a
+// directory, so Find and compaction only touch files of one site.
 package cookiejar_file
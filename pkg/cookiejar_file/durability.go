@@ -0,0 +1,134 @@
+package cookiejar_file
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/NateScarlet/cookiejar/internal/util"
+)
+
+type durabilityMode int
+
+const (
+	durabilityNone durabilityMode = iota
+	durabilityOnCompact
+	durabilityOnWrite
+	durabilityGroupCommit
+)
+
+// Durability defines when the repository calls fsync.
+type Durability struct {
+	mode     durabilityMode
+	interval time.Duration
+}
+
+var (
+	// DurabilityNone never calls fsync, the operating system decides
+	// when data reaches disk.
+	DurabilityNone = Durability{mode: durabilityNone}
+	// DurabilityOnCompact calls fsync on the rewritten file and its directory
+	// when compacting, so a power loss can not leave an empty file.
+	// Appended records may be lost. This is the default.
+	DurabilityOnCompact = Durability{mode: durabilityOnCompact}
+	// DurabilityOnWrite additionally calls fsync after every append,
+	// except touch records that only update access time.
+	DurabilityOnWrite = Durability{mode: durabilityOnWrite}
+)
+
+// DurabilityGroupCommit is like DurabilityOnWrite, but appends within
+// interval share one fsync in background. Failure of the fsync is returned
+// by next Save, DeleteMany or Compact.
+func DurabilityGroupCommit(interval time.Duration) Durability {
+	if interval <= 0 {
+		panic("group commit interval must be positive")
+	}
+	return Durability{mode: durabilityGroupCommit, interval: interval}
+}
+
+func (d Durability) String() string {
+	switch d.mode {
+	case durabilityNone:
+		return "DurabilityNone"
+	case durabilityOnCompact:
+		return "DurabilityOnCompact"
+	case durabilityOnWrite:
+		return "DurabilityOnWrite"
+	case durabilityGroupCommit:
+		return fmt.Sprintf("DurabilityGroupCommit(%s)", d.interval)
+	}
+	return fmt.Sprintf("Durability(%d)", d.mode)
+}
+
+func (r *entryRepository) atomicOptions() []util.AtomicOption {
+	return []util.AtomicOption{
+		util.AtomicOptionSync(r.durability.mode != durabilityNone),
+	}
+}
+
+// syncAppend is called after records appended to f, created is true when
+// the append created the file. caller should hold the lock.
+func (r *entryRepository) syncAppend(f *os.File, created bool) (err error) {
+	switch r.durability.mode {
+	case durabilityOnWrite:
+		err = f.Sync()
+		if err != nil {
+			return
+		}
+		if created {
+			err = util.SyncDir(filepath.Dir(r.filename))
+		}
+	case durabilityGroupCommit:
+		r.syncDir = r.syncDir || created
+		if !r.syncPending {
+			r.syncPending = true
+			time.AfterFunc(r.durability.interval, r.groupCommit)
+		}
+	}
+	return
+}
+
+// groupCommit calls fsync for appends since last commit.
+func (r *entryRepository) groupCommit() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.syncPending {
+		return
+	}
+	r.syncPending = false
+	var err = func() (err error) {
+		f, err := os.OpenFile(r.filename, os.O_WRONLY|os.O_APPEND, 0600)
+		if os.IsNotExist(err) {
+			// removed or renamed by compaction, which syncs itself.
+			return nil
+		}
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		err = f.Sync()
+		if err != nil {
+			return
+		}
+		if r.syncDir {
+			err = util.SyncDir(filepath.Dir(r.filename))
+			if err != nil {
+				return
+			}
+			r.syncDir = false
+		}
+		return
+	}()
+	if err != nil {
+		r.syncErr = fmt.Errorf("group commit: %w", err)
+	}
+}
+
+// takeSyncErr returns and clears error of last group commit. Touch does not
+// take it since the jar ignores touch errors, caller should hold the lock.
+func (r *entryRepository) takeSyncErr() (err error) {
+	err = r.syncErr
+	r.syncErr = nil
+	return
+}
@@ -0,0 +1,95 @@
+package cookiejar_file
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDurability(t *testing.T) {
+	var ctx = context.Background()
+	url1, _ := url.Parse("http://example.com")
+
+	for _, d := range []Durability{
+		DurabilityNone,
+		DurabilityOnCompact,
+		DurabilityOnWrite,
+		DurabilityGroupCommit(time.Millisecond),
+	} {
+		t.Run(d.String(), func(t *testing.T) {
+			var filename = path.Join(t.TempDir(), "cookies.jsonl")
+			var repo = NewEntryRepository(filename, OptionDurability(d))
+			jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo))
+			require.NoError(t, err)
+			jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+			jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "2"}})
+			require.NoError(t, repo.Compact())
+			var repo2 = NewEntryRepository(filename)
+			jar2, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo2))
+			require.NoError(t, err)
+			require.Len(t, jar2.Cookies(url1), 1)
+			assert.Equal(t, "2", jar2.Cookies(url1)[0].Value)
+		})
+	}
+
+	t.Run("should commit group in background", func(t *testing.T) {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		var repo = NewEntryRepository(filename, OptionDurability(DurabilityGroupCommit(10*time.Millisecond)))
+		var r = repo.(*entryRepository)
+		var pending = func() bool {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			return r.syncPending
+		}
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo))
+		require.NoError(t, err)
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		assert.True(t, pending())
+		assert.Eventually(t, func() bool { return !pending() }, time.Second, time.Millisecond)
+	})
+
+	t.Run("should return group commit error on next save", func(t *testing.T) {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		var repo = NewEntryRepository(filename, OptionDurability(DurabilityGroupCommit(time.Hour)))
+		var r = repo.(*entryRepository)
+		e, err := cookiejar.EntryFromRepository(
+			"example.com", "a", "1", "example.com", "/", "", false, false, false, true,
+			time.Time{}, time.Now(), 0,
+		)
+		require.NoError(t, err)
+		require.NoError(t, repo.Save(ctx, *e))
+		// fsync fails since a directory can not be opened for writing
+		require.NoError(t, os.Remove(filename))
+		require.NoError(t, os.Mkdir(filename, 0700))
+		r.groupCommit()
+		require.NoError(t, os.Remove(filename))
+
+		assert.NoError(t, repo.Touch(ctx, []string{e.ID()}, time.Now()))
+		assert.ErrorContains(t, repo.Save(ctx, *e), "group commit")
+		assert.NoError(t, repo.Save(ctx, *e))
+	})
+
+	t.Run("should not sync touch records", func(t *testing.T) {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		var repo = NewEntryRepository(filename, OptionDurability(DurabilityGroupCommit(time.Hour)))
+		var r = repo.(*entryRepository)
+		e, err := cookiejar.EntryFromRepository(
+			"example.com", "a", "1", "example.com", "/", "", false, false, false, true,
+			time.Time{}, time.Now(), 0,
+		)
+		require.NoError(t, err)
+		require.NoError(t, repo.Save(ctx, *e))
+		assert.True(t, r.syncPending)
+		r.groupCommit()
+		require.NoError(t, repo.Touch(ctx, []string{e.ID()}, time.Now()))
+		assert.False(t, r.syncPending)
+	})
+}
@@ -0,0 +1,133 @@
+package cookiejar_file
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// ErrUnauthenticatedRecord is returned when a record can not be decrypted
+// and authenticated with keys from the key provider, or a plaintext record is
+// found while encryption is enabled.
+var ErrUnauthenticatedRecord = errors.New("cookiejar_file: unauthenticated record")
+
+// KeyProvider provides AES keys (16, 24 or 32 bytes) for value encryption.
+type KeyProvider interface {
+	// CurrentKey returns the key used to encrypt new records.
+	CurrentKey() (id string, key []byte, err error)
+	// Key returns the key by id to decrypt existing records.
+	Key(id string) (key []byte, err error)
+}
+
+type staticKeyProvider struct {
+	current string
+	keys    map[string][]byte
+}
+
+func (p staticKeyProvider) CurrentKey() (id string, key []byte, err error) {
+	key, err = p.Key(p.current)
+	return p.current, key, err
+}
+
+func (p staticKeyProvider) Key(id string) (key []byte, err error) {
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key '%s'", id)
+	}
+	return key, nil
+}
+
+// StaticKeyProvider returns a KeyProvider with fixed keys,
+// keep old keys in keys until records are re-encrypted by Compact.
+func StaticKeyProvider(currentID string, keys map[string][]byte) KeyProvider {
+	if _, ok := keys[currentID]; !ok {
+		panic("current key not in keys")
+	}
+	return staticKeyProvider{currentID, keys}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// isValueRecord reports whether i holds an entry value,
+// tombstones and touch records do not.
+func (i entry) isValueRecord() bool {
+	return newNullTime(i.Deleted).IsNull() && newNullTime(i.Touched).IsNull()
+}
+
+// encrypt value of i with current key, record id is used as additional data
+// so encrypted value can not be moved to another record.
+func (r *entryRepository) encrypt(i entry) (_ entry, err error) {
+	if r.keyProvider == nil || !i.isValueRecord() {
+		return i, nil
+	}
+	id, key, err := r.keyProvider.CurrentKey()
+	if err != nil {
+		return
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return
+	}
+	var nonce = make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return
+	}
+	i.EncryptedValue = aead.Seal(nonce, nonce, []byte(i.Value), []byte(i.ID))
+	i.KeyID = id
+	i.Value = ""
+	return i, nil
+}
+
+// decrypt value of i, plaintext record is only accepted when encryption
+// disabled or plaintext allowed.
+func (r *entryRepository) decrypt(i entry) (_ entry, err error) {
+	if !i.isValueRecord() {
+		return i, nil
+	}
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("%w: record '%s': %w", ErrUnauthenticatedRecord, i.ID, err)
+		}
+	}()
+	if i.EncryptedValue == nil {
+		if r.keyProvider != nil && !r.allowPlaintext {
+			err = errors.New("plaintext record")
+			return
+		}
+		return i, nil
+	}
+	if r.keyProvider == nil {
+		err = errors.New("encrypted record requires key provider")
+		return
+	}
+	key, err := r.keyProvider.Key(i.KeyID)
+	if err != nil {
+		return
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return
+	}
+	if len(i.EncryptedValue) < aead.NonceSize() {
+		err = errors.New("ciphertext too short")
+		return
+	}
+	var nonce, ciphertext = i.EncryptedValue[:aead.NonceSize()], i.EncryptedValue[aead.NonceSize():]
+	value, err := aead.Open(nil, nonce, ciphertext, []byte(i.ID))
+	if err != nil {
+		return
+	}
+	i.Value = string(value)
+	i.EncryptedValue = nil
+	i.KeyID = ""
+	return i, nil
+}
@@ -0,0 +1,133 @@
+package cookiejar_file
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryption(t *testing.T) {
+	var ctx = context.Background()
+	url1, _ := url.Parse("http://example.com")
+	var key1 = bytes.Repeat([]byte{1}, 32)
+	var key2 = bytes.Repeat([]byte{2}, 32)
+	var useJar = func(t *testing.T, filename string, options ...Option) (cookiejar.Jar, EntryRepository) {
+		var repo = NewEntryRepository(filename, options...)
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo))
+		require.NoError(t, err)
+		return jar, repo
+	}
+	var readValues = func(t *testing.T, repo EntryRepository) (ret []string, err error) {
+		err = repo.FindAll(ctx).ForEach(func(i cookiejar.Entry) (err error) {
+			ret = append(ret, i.Value())
+			return
+		})
+		return
+	}
+
+	t.Run("should encrypt value", func(t *testing.T) {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		var jar, _ = useJar(t, filename, OptionEncryption(StaticKeyProvider("1", map[string][]byte{"1": key1})))
+		jar.SetCookies(url1, []*http.Cookie{{Name: "session", Value: "secret-value"}})
+		data, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "secret-value")
+		assert.Contains(t, string(data), `"keyId":"1"`)
+
+		var jar2, _ = useJar(t, filename, OptionEncryption(StaticKeyProvider("1", map[string][]byte{"1": key1})))
+		var cookies = jar2.Cookies(url1)
+		require.Len(t, cookies, 1)
+		assert.Equal(t, "secret-value", cookies[0].Value)
+	})
+
+	t.Run("should refuse wrong key", func(t *testing.T) {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		var jar, _ = useJar(t, filename, OptionEncryption(StaticKeyProvider("1", map[string][]byte{"1": key1})))
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+
+		_, err := readValues(t, NewEntryRepository(filename, OptionEncryption(StaticKeyProvider("1", map[string][]byte{"1": key2}))))
+		assert.ErrorIs(t, err, ErrUnauthenticatedRecord)
+		_, err = readValues(t, NewEntryRepository(filename))
+		assert.ErrorIs(t, err, ErrUnauthenticatedRecord)
+	})
+
+	t.Run("should refuse moved value", func(t *testing.T) {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		var jar, _ = useJar(t, filename, OptionEncryption(StaticKeyProvider("1", map[string][]byte{"1": key1})))
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		data, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filename, []byte(strings.ReplaceAll(string(data), ";a", ";b")), 0600))
+
+		_, err = readValues(t, NewEntryRepository(filename, OptionEncryption(StaticKeyProvider("1", map[string][]byte{"1": key1}))))
+		assert.ErrorIs(t, err, ErrUnauthenticatedRecord)
+	})
+
+	t.Run("should drop unauthenticated record on repair", func(t *testing.T) {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		var keys = StaticKeyProvider("1", map[string][]byte{"1": key1})
+		var jar, _ = useJar(t, filename, OptionEncryption(keys))
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		data, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		var lines = strings.Split(strings.TrimSpace(string(data)), "\n")
+		var moved = strings.ReplaceAll(lines[len(lines)-1], ";a", ";b")
+		require.NoError(t, os.WriteFile(filename, []byte(string(data)+moved+"\n"), 0600))
+
+		var reported []*MalformedLineError
+		var repo = NewEntryRepository(filename, OptionEncryption(keys), OptionOnMalformedLine(func(err *MalformedLineError) {
+			reported = append(reported, err)
+		}))
+		require.NoError(t, repo.Repair())
+		require.Len(t, reported, 1)
+		assert.Equal(t, len(lines)+1, reported[0].Line)
+		assert.ErrorIs(t, reported[0], ErrUnauthenticatedRecord)
+		values, err := readValues(t, NewEntryRepository(filename, OptionEncryption(keys)))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"1"}, values)
+	})
+
+	t.Run("should migrate plaintext", func(t *testing.T) {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		var jar, _ = useJar(t, filename)
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "plain"}})
+
+		var keys = StaticKeyProvider("1", map[string][]byte{"1": key1})
+		_, err := readValues(t, NewEntryRepository(filename, OptionEncryption(keys)))
+		assert.ErrorIs(t, err, ErrUnauthenticatedRecord)
+
+		var repo = NewEntryRepository(filename, OptionEncryption(keys), OptionAllowPlaintext())
+		require.NoError(t, repo.Compact())
+		data, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "plain")
+		values, err := readValues(t, NewEntryRepository(filename, OptionEncryption(keys)))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"plain"}, values)
+	})
+
+	t.Run("should rotate key on compact", func(t *testing.T) {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		var jar, _ = useJar(t, filename, OptionEncryption(StaticKeyProvider("1", map[string][]byte{"1": key1})))
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+
+		var repo = NewEntryRepository(filename, OptionEncryption(StaticKeyProvider("2", map[string][]byte{"1": key1, "2": key2})))
+		require.NoError(t, repo.Compact())
+		data, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), `"keyId":"1"`)
+
+		values, err := readValues(t, NewEntryRepository(filename, OptionEncryption(StaticKeyProvider("2", map[string][]byte{"2": key2}))))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"1"}, values)
+	})
+}
@@ -50,39 +50,47 @@ func newNullTime(t *time.Time) *nullTime {
 }
 
 type entry struct {
-	ID         string     `json:"id,omitempty"`
-	Key        string     `json:"key,omitempty"`
-	Name       string     `json:"name,omitempty"`
-	Value      string     `json:"value,omitempty"`
-	Domain     string     `json:"domain,omitempty"`
-	Path       string     `json:"path,omitempty"`
-	SameSite   string     `json:"sameSite,omitempty"`
-	Secure     bool       `json:"secure,omitempty"`
-	HttpOnly   bool       `json:"httpOnly,omitempty"`
-	Persistent bool       `json:"persistent,omitempty"`
-	HostOnly   bool       `json:"hostOnly,omitempty"`
-	Expires    *time.Time `json:"expires,omitempty"`
-	Creation   *time.Time `json:"creation,omitempty"`
-	Deleted    *time.Time `json:"deleted,omitempty"`
-	Order      int        `json:"order,omitempty"`
+	ID    string `json:"id,omitempty"`
+	Key   string `json:"key,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Value string `json:"value,omitempty"`
+	// EncryptedValue is nonce and AES-GCM sealed value.
+	EncryptedValue []byte     `json:"encryptedValue,omitempty"`
+	KeyID          string     `json:"keyId,omitempty"`
+	Domain         string     `json:"domain,omitempty"`
+	Path           string     `json:"path,omitempty"`
+	SameSite       string     `json:"sameSite,omitempty"`
+	Secure         bool       `json:"secure,omitempty"`
+	HttpOnly       bool       `json:"httpOnly,omitempty"`
+	Persistent     bool       `json:"persistent,omitempty"`
+	HostOnly       bool       `json:"hostOnly,omitempty"`
+	PartitionKey   string     `json:"partitionKey,omitempty"`
+	Expires        *time.Time `json:"expires,omitempty"`
+	Creation       *time.Time `json:"creation,omitempty"`
+	LastAccess     *time.Time `json:"lastAccess,omitempty"`
+	Deleted        *time.Time `json:"deleted,omitempty"`
+	Touched        *time.Time `json:"touched,omitempty"`
+	Order          int        `json:"order,omitempty"`
 }
 
 func newEntry(do cookiejar.Entry) *entry {
 	return &entry{
-		ID:         do.ID(),
-		Key:        do.Key(),
-		Name:       do.Name(),
-		Value:      do.Value(),
-		Domain:     do.Domain(),
-		Path:       do.Path(),
-		SameSite:   do.SameSite(),
-		Secure:     do.Secure(),
-		HttpOnly:   do.HttpOnly(),
-		Persistent: do.Persistent(),
-		HostOnly:   do.HostOnly(),
-		Expires:    nullTime{do.Expires()}.PtrValue(),
-		Creation:   nullTime{do.Creation()}.PtrValue(),
-		Order:      do.Order(),
+		ID:           do.ID(),
+		Key:          do.Key(),
+		Name:         do.Name(),
+		Value:        do.Value(),
+		Domain:       do.Domain(),
+		Path:         do.Path(),
+		SameSite:     do.SameSite(),
+		Secure:       do.Secure(),
+		HttpOnly:     do.HttpOnly(),
+		Persistent:   do.Persistent(),
+		HostOnly:     do.HostOnly(),
+		PartitionKey: do.PartitionKey(),
+		Expires:      nullTime{do.Expires()}.PtrValue(),
+		Creation:     nullTime{do.Creation()}.PtrValue(),
+		LastAccess:   nullTime{do.LastAccess()}.PtrValue(),
+		Order:        do.Order(),
 	}
 }
 
@@ -101,5 +109,7 @@ func (obj entry) DomainObject() (_ *cookiejar.Entry, err error) {
 		newNullTime(obj.Expires).ValueOr(endOfTime),
 		newNullTime(obj.Creation).Value(),
 		obj.Order,
+		cookiejar.EntryOptionPartitionKey(obj.PartitionKey),
+		cookiejar.EntryOptionLastAccess(newNullTime(obj.LastAccess).ValueOr(newNullTime(obj.Creation).Value())),
 	)
 }
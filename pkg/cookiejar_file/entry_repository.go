@@ -1,7 +1,8 @@
 package cookiejar_file
 
 import (
-	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"errors"
@@ -10,22 +11,83 @@ import (
 	"sync"
 	"time"
 
-	"github.com/NateScarlet/cookiejar/internal/util"
 	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
 )
 
 type EntryRepository interface {
 	cookiejar.EntryRepository
+	cookiejar.EntryLister
+	cookiejar.EntryToucher
 	Compact() (err error)
+	Repair() (err error)
+	Stats() (Stats, error)
 	Filename() string
 }
 
+// Stats describes file usage of the repository.
+type Stats struct {
+	// Entries is count of live entries.
+	Entries int
+	// Lines is count of records in the file,
+	// lines other than live entries are removed by compaction.
+	Lines int
+	// Tombstones is count of deletion records in the file.
+	Tombstones int
+	// Bytes is the file size.
+	Bytes int64
+}
+
+// Dead returns count of lines that compaction can remove.
+func (s Stats) Dead() int {
+	return s.Lines - s.Entries
+}
+
 type entryRepository struct {
-	filename string
-	mu       sync.Mutex
+	filename        string
+	clock           cookiejar.Clock
+	lockTimeout     time.Duration
+	compactRatio    float64
+	compactSize     int64
+	onCompactError  func(err error)
+	onMalformedLine func(err *MalformedLineError)
+	durability      Durability
+	keyProvider     KeyProvider
+	allowPlaintext  bool
+	compression     bool
+
+	mu sync.Mutex
+	// index is nil until loaded or after invalidated.
+	index *index
+	// info is the file state when index is up to date,
+	// nil if file not exists.
+	info os.FileInfo
+	// baseInfo is the compressed base file state like info.
+	baseInfo os.FileInfo
+	tail     tail
+	// outdated is true when file is written in older format version,
+	// it is compacted before next write.
+	outdated bool
+	// syncPending is true when group commit is scheduled.
+	syncPending bool
+	// syncDir is true when directory should be synced by group commit.
+	syncDir bool
+	// syncErr is error of last group commit,
+	// returned by next Save, DeleteMany or Compact.
+	syncErr error
 }
 
-func (r *entryRepository) forEachRaw(cb func(i entry) (err error)) (err error) {
+// forEachRaw reads records in the base file then the file.
+func (r *entryRepository) forEachRaw(cb func(i entry) (err error)) (baseInfo, info os.FileInfo, t tail, err error) {
+	t.torn = -1
+	t.version = formatVersion
+	baseInfo, base, err := r.forEachBase(cb)
+	if err != nil {
+		return
+	}
+	defer func() {
+		// report oldest version
+		t.version = min(t.version, base.version)
+	}()
 	f, err := os.Open(r.filename)
 	if errors.Is(err, os.ErrNotExist) {
 		err = nil
@@ -35,21 +97,235 @@ func (r *entryRepository) forEachRaw(cb func(i entry) (err error)) (err error) {
 		return
 	}
 	defer f.Close()
+	info, err = f.Stat()
+	if err != nil {
+		return
+	}
+	t, err = readRecords(f, cb, r.onMalformedLine)
+	return
+}
 
-	var s = bufio.NewScanner(f)
-	for s.Scan() {
-		var i = new(entry)
-		err = json.Unmarshal(s.Bytes(), i)
+// load reads the file into index, unless it is unchanged since last load.
+// caller should hold the lock.
+func (r *entryRepository) load() (err error) {
+	info, err := statOptional(r.filename)
+	if err != nil {
+		return
+	}
+	baseInfo, err := statOptional(r.baseFilename())
+	if err != nil {
+		return
+	}
+	if r.index != nil &&
+		sameOptionalFileState(r.info, info) &&
+		sameOptionalFileState(r.baseInfo, baseInfo) {
+		return
+	}
+	var idx = newIndex()
+	baseInfo, info, t, err := r.forEachRaw(func(i entry) (err error) {
+		i, err = r.decrypt(i)
 		if err != nil {
 			return
 		}
-		err = cb(*i)
+		idx.apply(i)
+		return
+	})
+	if err != nil {
+		r.index = nil
+		return
+	}
+	r.index = idx
+	r.info = info
+	r.baseInfo = baseInfo
+	r.tail = t
+	r.outdated = t.version < formatVersion
+	return
+}
+
+// loadShared calls load with shared file lock.
+// caller should hold the lock.
+func (r *entryRepository) loadShared() (err error) {
+	unlock, err := r.lockFile(false)
+	if err != nil {
+		return
+	}
+	defer unlock()
+	return r.load()
+}
+
+// appendRecords writes records to the file and index, sync is false for
+// touch records that are fine to lose. caller should hold the lock.
+func (r *entryRepository) appendRecords(sync bool, records ...entry) (err error) {
+	unlock, err := r.lockFile(true)
+	if err != nil {
+		return
+	}
+	defer unlock()
+	err = r.load()
+	if err != nil {
+		return
+	}
+	if r.outdated {
+		err = r.compact()
+		if err != nil {
+			return
+		}
+	}
+	var created = r.info == nil
+	var buf bytes.Buffer
+	var encoder = json.NewEncoder(&buf)
+	var base int64
+	if r.info != nil {
+		base = r.info.Size()
+	}
+	if r.tail.torn >= 0 {
+		// drop torn line so appended records stay line aligned
+		base = r.tail.torn
+	} else if r.tail.unterminated {
+		buf.WriteByte('\n')
+	}
+	if base == 0 {
+		err = encoder.Encode(header{Version: formatVersion})
 		if err != nil {
 			return
 		}
 	}
+	for _, i := range records {
+		var po entry
+		po, err = r.encrypt(i)
+		if err != nil {
+			return
+		}
+		err = encoder.Encode(po)
+		if err != nil {
+			return
+		}
+	}
+	f, err := os.OpenFile(r.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if r.tail.torn >= 0 {
+		err = f.Truncate(base)
+		if err != nil {
+			r.index = nil
+			return
+		}
+	}
+	_, err = f.Write(buf.Bytes())
+	if err != nil {
+		r.index = nil
+		return
+	}
+	if sync {
+		err = r.syncAppend(f, created)
+		if err != nil {
+			r.index = nil
+			return
+		}
+	}
+	info, err := f.Stat()
+	if err != nil {
+		r.index = nil
+		return
+	}
+	var expectedSize = base + int64(buf.Len())
+	if r.info != nil && !os.SameFile(r.info, info) {
+		expectedSize = -1
+	}
+	if info.Size() != expectedSize {
+		// changed by others, reload on next read.
+		r.index = nil
+		return
+	}
+	for _, i := range records {
+		r.index.apply(i)
+	}
+	r.info = info
+	r.tail = tail{torn: -1, version: formatVersion}
+	r.autoCompact()
 	return
+}
+
+func (r *entryRepository) stats() Stats {
+	var ret = Stats{
+		Entries:    r.index.entries(),
+		Lines:      r.index.lines,
+		Tombstones: r.index.tombstones,
+	}
+	if r.info != nil {
+		ret.Bytes += r.info.Size()
+	}
+	if r.baseInfo != nil {
+		ret.Bytes += r.baseInfo.Size()
+	}
+	return ret
+}
+
+// shouldCompact checks compaction thresholds.
+// caller should hold the lock.
+func (r *entryRepository) shouldCompact() bool {
+	var s = r.stats()
+	if s.Dead() == 0 {
+		return false
+	}
+	if r.compactRatio > 0 && float64(s.Dead()) > r.compactRatio*float64(max(s.Entries, 1)) {
+		return true
+	}
+	if r.compactSize > 0 && s.Bytes > r.compactSize {
+		return true
+	}
+	return false
+}
+
+// autoCompact compacts the file when thresholds crossed,
+// caller should hold the lock.
+func (r *entryRepository) autoCompact() {
+	if !r.shouldCompact() {
+		return
+	}
+	var err = r.compact()
+	if err != nil {
+		r.onCompactError(fmt.Errorf("cookiejar_file: entryRepository.autoCompact: %w", err))
+	}
+}
+
+// compactDead compacts the file when it has dead lines.
+func (r *entryRepository) compactDead() (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	unlock, err := r.lockFile(true)
+	if err != nil {
+		return
+	}
+	defer unlock()
+	err = r.load()
+	if err != nil {
+		return
+	}
+	if r.stats().Dead() == 0 {
+		return
+	}
+	return r.compact()
+}
 
+// compactEvery compacts the file when it has dead lines on each tick,
+// until ctx done.
+func (r *entryRepository) compactEvery(ctx context.Context, interval time.Duration) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		var err = r.compactDead()
+		if err != nil {
+			r.onCompactError(fmt.Errorf("cookiejar_file: entryRepository.compactEvery: %w", err))
+		}
+	}
 }
 
 // Delete implements EntryRepository
@@ -71,74 +347,99 @@ func (r *entryRepository) DeleteMany(ctx context.Context, id []string) (err erro
 	}()
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	f, err := os.OpenFile(r.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	err = r.takeSyncErr()
 	if err != nil {
 		return
 	}
-	defer f.Close()
-	var encoder = json.NewEncoder(f)
+	var records = make([]entry, 0, len(id))
 	for _, i := range id {
-		err = encoder.Encode(entry{
+		records = append(records, entry{
 			ID:      i,
-			Deleted: nullTime{time.Now()}.PtrValue(),
+			Deleted: nullTime{r.clock.Now()}.PtrValue(),
 		})
-		if err != nil {
-			return
-		}
 	}
-	return
+	return r.appendRecords(true, records...)
 }
 
-func (r *entryRepository) forEach(filter func(i entry) bool, cb func(i entry) (err error)) (err error) {
-	var m = make(map[string]entry)
-	err = r.forEachRaw(func(i entry) (err error) {
-		if !filter(i) {
-			return
-		}
-		if !newNullTime(i.Deleted).IsNull() {
-			delete(m, i.ID)
-			return
-		}
-		if old, ok := m[i.ID]; ok {
-			i.Creation = old.Creation
-			i.Order = old.Order
+func (r *entryRepository) iterate(entries []entry, cb func(i cookiejar.Entry) (err error)) (err error) {
+	for _, i := range entries {
+		do, err := i.DomainObject()
+		if err != nil {
+			return err
 		}
-		m[i.ID] = i
-		return
-	})
-	if err != nil {
-		return
-	}
-	for _, i := range m {
-		err = cb(i)
+		err = cb(*do)
 		if err != nil {
-			return
+			return err
 		}
 	}
 	return
 }
 
-// Find implements EntryRepository
+// Find implements EntryRepository,
+// the file is only read again when changed by others.
 func (r *entryRepository) Find(ctx context.Context, key string) cookiejar.EntryIterator {
-	r.mu.Lock()
-	defer r.mu.Unlock()
 	return cookiejar.EntryIteratorFunc(func(cb func(i cookiejar.Entry) (err error)) (err error) {
 		defer func() {
 			if err != nil {
 				err = fmt.Errorf("cookiejar_file: entryRepository.Find('%s'): %w", key, err)
 			}
 		}()
-		return r.forEach(func(v entry) bool { return v.Key == key }, func(i entry) (err error) {
-			do, err := i.DomainObject()
+		r.mu.Lock()
+		err = r.loadShared()
+		var entries []entry
+		if err == nil {
+			entries = r.index.find(key)
+		}
+		r.mu.Unlock()
+		if err != nil {
+			return
+		}
+		return r.iterate(entries, cb)
+	})
+}
+
+// FindAll implements EntryLister
+func (r *entryRepository) FindAll(ctx context.Context) cookiejar.EntryIterator {
+	return cookiejar.EntryIteratorFunc(func(cb func(i cookiejar.Entry) (err error)) (err error) {
+		defer func() {
 			if err != nil {
-				return
+				err = fmt.Errorf("cookiejar_file: entryRepository.FindAll: %w", err)
 			}
-			err = cb(*do)
+		}()
+		r.mu.Lock()
+		err = r.loadShared()
+		var entries []entry
+		if err == nil {
+			entries = r.index.findAll()
+		}
+		r.mu.Unlock()
+		if err != nil {
 			return
-		})
+		}
+		return r.iterate(entries, cb)
 	})
 }
 
+// Touch implements EntryToucher,
+// it appends a record only contains id and access time without fsync.
+func (r *entryRepository) Touch(ctx context.Context, id []string, t time.Time) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_file: entryRepository.Touch(%s): %w", id, err)
+		}
+	}()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var records = make([]entry, 0, len(id))
+	for _, i := range id {
+		records = append(records, entry{
+			ID:      i,
+			Touched: nullTime{t}.PtrValue(),
+		})
+	}
+	return r.appendRecords(false, records...)
+}
+
 // Save implements EntryRepository
 func (r *entryRepository) Save(ctx context.Context, entry cookiejar.Entry) (err error) {
 	defer func() {
@@ -148,51 +449,200 @@ func (r *entryRepository) Save(ctx context.Context, entry cookiejar.Entry) (err
 	}()
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	f, err := os.OpenFile(r.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	err = r.takeSyncErr()
 	if err != nil {
 		return
 	}
-	defer f.Close()
-	var encoder = json.NewEncoder(f)
-	po := newEntry(entry)
-	err = encoder.Encode(po)
+	return r.appendRecords(true, *newEntry(entry))
+}
+
+func (r *entryRepository) Compact() (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_file: entryRepository.Compact: %w", err)
+		}
+	}()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err = r.takeSyncErr()
 	if err != nil {
 		return
 	}
-	return
+	unlock, err := r.lockFile(true)
+	if err != nil {
+		return
+	}
+	defer unlock()
+	return r.compact()
 }
 
-func (r *entryRepository) Compact() (err error) {
+// Repair implements EntryRepository,
+// it rewrites the file with valid records only, malformed lines are reported
+// to OptionOnMalformedLine callback. Records before a corrupted part of the
+// compressed base file are kept, and records that can not be authenticated
+// are dropped.
+func (r *entryRepository) Repair() (err error) {
 	defer func() {
 		if err != nil {
-			err = fmt.Errorf("cookiejar_file: entryRepository.Compact: %w", err)
+			err = fmt.Errorf("cookiejar_file: entryRepository.Repair: %w", err)
 		}
 	}()
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	return util.AtomicSave(r.filename, func(f *os.File) (err error) {
-		err = f.Chmod(0600)
+	unlock, err := r.lockFile(true)
+	if err != nil {
+		return
+	}
+	defer unlock()
+	var records []entry
+	var onMalformed = func(err *MalformedLineError) {
+		if r.onMalformedLine != nil {
+			r.onMalformedLine(err)
+		}
+	}
+	var check = func(i entry) (err error) {
+		_, err = r.decrypt(i)
+		return
+	}
+	var appendRecord = func(i entry) (err error) {
+		records = append(records, i)
+		return
+	}
+	err = func() (err error) {
+		f, err := os.Open(r.baseFilename())
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			// nothing can be salvaged without a valid header
+			onMalformed(&MalformedLineError{Line: 1, Err: err})
+			return nil
+		}
+		defer zr.Close()
+		var sr = &salvageReader{rd: zr}
+		_, err = readCheckedRecords(sr, check, appendRecord, onMalformed)
 		if err != nil {
 			return
 		}
-		var encoder = json.NewEncoder(f)
-		err = r.forEach(
-			func(i entry) bool { return true },
-			func(i entry) (err error) {
-				return encoder.Encode(i)
-			},
-		)
+		if malformed := sr.malformed(); malformed != nil {
+			onMalformed(malformed)
+		}
 		return
-	})
+	}()
+	if err != nil {
+		return
+	}
+	err = func() (err error) {
+		f, err := os.Open(r.filename)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		_, err = readCheckedRecords(f, check, appendRecord, onMalformed)
+		return
+	}()
+	if err != nil {
+		return
+	}
+	err = r.rewrite(records)
+	if err != nil {
+		return
+	}
+	r.index = nil
+	return r.load()
+}
+
+// Stats implements EntryRepository
+func (r *entryRepository) Stats() (_ Stats, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_file: entryRepository.Stats: %w", err)
+		}
+	}()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err = r.loadShared()
+	if err != nil {
+		return
+	}
+	return r.stats(), nil
+}
+
+// compact rewrites the file with live entries only,
+// caller should hold the lock.
+func (r *entryRepository) compact() (err error) {
+	err = r.load()
+	if err != nil {
+		return
+	}
+	var entries = r.index.findAll()
+	var records = make([]entry, 0, len(entries))
+	for _, i := range entries {
+		// re-encrypt with current key
+		i, err = r.encrypt(i)
+		if err != nil {
+			return
+		}
+		records = append(records, i)
+	}
+	err = r.rewrite(records)
+	if err != nil {
+		return
+	}
+	info, err := statOptional(r.filename)
+	if err != nil {
+		r.index = nil
+		return
+	}
+	baseInfo, err := statOptional(r.baseFilename())
+	if err != nil {
+		r.index = nil
+		return
+	}
+	r.index.compacted()
+	r.info = info
+	r.baseInfo = baseInfo
+	r.tail = tail{torn: -1, version: formatVersion}
+	r.outdated = false
+	return
+}
+
+func newEntryRepository(filename string, opts *Options) *entryRepository {
+	return &entryRepository{
+		filename:        filename,
+		clock:           opts.clock,
+		lockTimeout:     opts.lockTimeout,
+		compactRatio:    opts.compactRatio,
+		compactSize:     opts.compactSize,
+		onCompactError:  opts.onCompactError,
+		onMalformedLine: opts.onMalformedLine,
+		durability:      opts.durability,
+		keyProvider:     opts.keyProvider,
+		compression:     opts.compression,
+		allowPlaintext:  opts.allowPlaintext,
+	}
 }
 
 // NewEntryRepository use filename to store cookies
 // will use `.tmp` as tmp file suffix, and `~` as backupSuffix
-func NewEntryRepository(filename string) EntryRepository {
+func NewEntryRepository(filename string, options ...Option) EntryRepository {
 	if filename == "" {
 		panic("empty filename")
 	}
-	return &entryRepository{filename: filename}
+	var opts = newOptions(options...)
+	var r = newEntryRepository(filename, opts)
+	if opts.compactInterval > 0 {
+		go r.compactEvery(opts.compactIntervalCtx, opts.compactInterval)
+	}
+	return r
 }
 
 func (obj *entryRepository) Filename() string {
@@ -31,7 +31,7 @@ func snapshotEntryRepository(t *testing.T, repo EntryRepository) {
 func TestEntryRepository(t *testing.T) {
 	var ctx = context.Background()
 	url1, _ := url.Parse("http://example.com")
-	var useJar = func(t *testing.T) (cookiejar.Jar, EntryRepository) {
+	var useJar = func(t *testing.T) (cookiejar.Jar, EntryRepository, *test_util.Clock) {
 		t.Parallel()
 		dir, err := os.MkdirTemp("", strings.Replace(t.Name(), "/", "-", -1))
 		require.NoError(t, err)
@@ -39,13 +39,14 @@ func TestEntryRepository(t *testing.T) {
 			require.NoError(t, os.RemoveAll(dir))
 		})
 		var filename = path.Join(dir, "cookies.jsonl")
-		var repo = NewEntryRepository(filename)
-		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo))
+		var clock = test_util.NewClock(time.Now())
+		var repo = NewEntryRepository(filename, OptionClock(clock))
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo), cookiejar.OptionClock(clock))
 		require.NoError(t, err)
-		return jar, repo
+		return jar, repo, clock
 	}
 	t.Run("should able to save", func(t *testing.T) {
-		var jar, repo = useJar(t)
+		var jar, repo, _ = useJar(t)
 		u, _ := url.Parse("http://example.com")
 		jar.SetCookies(u, []*http.Cookie{
 			{Name: "a", Value: "1", Path: "/"},
@@ -55,32 +56,64 @@ func TestEntryRepository(t *testing.T) {
 	})
 
 	t.Run("should able to delete", func(t *testing.T) {
-		var jar, repo = useJar(t)
+		var jar, repo, clock = useJar(t)
 		jar.SetCookies(url1, []*http.Cookie{
-			{Name: "a", Value: "1", Path: "/", Expires: time.Now().Add(time.Second)},
+			{Name: "a", Value: "1", Path: "/", Expires: clock.Now().Add(time.Second)},
 		})
 		assert.Len(t, jar.Cookies(url1), 1)
-		time.Sleep(time.Second + 1)
+		clock.Add(time.Second + 1)
 		assert.Len(t, jar.Cookies(url1), 0)
 		snapshotEntryRepository(t, repo)
 	})
 
+	t.Run("should able to delete before expires", func(t *testing.T) {
+		var jar, repo, _ = useJar(t)
+		jar.SetCookies(url1, []*http.Cookie{
+			{Name: "a", Value: "1", Path: "/"},
+		})
+		jar.SetCookies(url1, []*http.Cookie{
+			{Name: "a", Path: "/", MaxAge: -1},
+		})
+		jar2, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo))
+		require.NoError(t, err)
+		assert.Len(t, jar2.Cookies(url1), 0)
+	})
+
+	t.Run("should keep last access after compact", func(t *testing.T) {
+		var jar, repo, clock = useJar(t)
+		jar.SetCookies(url1, []*http.Cookie{
+			{Name: "a", Value: "1", Path: "/"},
+		})
+		var before time.Time
+		require.NoError(t, repo.FindAll(ctx).ForEach(func(i cookiejar.Entry) (err error) {
+			before = i.LastAccess()
+			return
+		}))
+		clock.Add(time.Minute)
+		assert.Len(t, jar.Cookies(url1), 1)
+		require.NoError(t, repo.Compact())
+		require.NoError(t, repo.FindAll(ctx).ForEach(func(i cookiejar.Entry) (err error) {
+			assert.True(t, i.LastAccess().After(before))
+			return
+		}))
+	})
+
 	t.Run("should remove deleted item after compact", func(t *testing.T) {
-		var jar, repo = useJar(t)
+		var jar, repo, clock = useJar(t)
 		jar.SetCookies(url1, []*http.Cookie{
-			{Name: "a", Value: "1", Path: "/", Expires: time.Now().Add(time.Second)},
+			{Name: "a", Value: "1", Path: "/", Expires: clock.Now().Add(time.Second)},
 		})
 		assert.Len(t, jar.Cookies(url1), 1)
-		time.Sleep(time.Second + 1)
+		clock.Add(time.Second + 1)
 		assert.Len(t, jar.Cookies(url1), 0)
 		require.NoError(t, repo.Compact())
 		snapshotEntryRepository(t, repo)
 	})
 
 	t.Run("should keep latest item after compact", func(t *testing.T) {
-		var jar, repo = useJar(t)
+		var jar, repo, clock = useJar(t)
 		jar.SetCookies(url1, []*http.Cookie{
-			{Name: "a", Value: "1", Path: "/", Expires: time.Now().Add(time.Second)},
+			{Name: "a", Value: "1", Path: "/", Expires: clock.Now().Add(time.Second)},
 		})
 		jar.SetCookies(url1, []*http.Cookie{
 			{Name: "a", Value: "2", Path: "/"},
@@ -90,7 +123,7 @@ func TestEntryRepository(t *testing.T) {
 	})
 
 	t.Run("should able to read", func(t *testing.T) {
-		var jar, repo = useJar(t)
+		var jar, repo, _ = useJar(t)
 		jar.SetCookies(url1, []*http.Cookie{
 			{Name: "a", Value: "1", Path: "/"},
 		})
@@ -99,8 +132,143 @@ func TestEntryRepository(t *testing.T) {
 		assert.Len(t, jar2.Cookies(url1), 1)
 	})
 
+	t.Run("should keep partitions separate", func(t *testing.T) {
+		var jar, repo, _ = useJar(t)
+		u, _ := url.Parse("https://widget.example.com")
+		for _, site := range []string{"https://example.org", "https://example.net"} {
+			topLevelSite, _ := url.Parse(site)
+			jar.SetCookiesForRequest(u, cookiejar.RequestContext{TopLevelSite: topLevelSite}, []*http.Cookie{
+				{Name: "a", Value: site, Path: "/", Secure: true, Partitioned: true},
+			})
+		}
+		jar2, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo))
+		require.NoError(t, err)
+		for _, site := range []string{"https://example.org", "https://example.net"} {
+			topLevelSite, _ := url.Parse(site)
+			var cookies = jar2.CookiesForRequest(u, cookiejar.RequestContext{TopLevelSite: topLevelSite})
+			require.Len(t, cookies, 1)
+			assert.Equal(t, site, cookies[0].Value)
+		}
+	})
+
 	t.Run("should able to read before write", func(t *testing.T) {
-		var jar, _ = useJar(t)
+		var jar, _, _ = useJar(t)
 		assert.Len(t, jar.Cookies(url1), 0)
 	})
 }
+
+func TestEntryRepositoryIndex(t *testing.T) {
+	var ctx = context.Background()
+	url1, _ := url.Parse("http://example.com")
+	var filename = path.Join(t.TempDir(), "cookies.jsonl")
+	var clock = test_util.NewClock(time.Now())
+	var repo1 = NewEntryRepository(filename, OptionClock(clock))
+	var repo2 = NewEntryRepository(filename, OptionClock(clock))
+	jar1, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo1), cookiejar.OptionClock(clock))
+	require.NoError(t, err)
+	jar2, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo2), cookiejar.OptionClock(clock))
+	require.NoError(t, err)
+
+	t.Run("should not reload unchanged file", func(t *testing.T) {
+		jar1.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		assert.Len(t, jar1.Cookies(url1), 1)
+		var idx = repo1.(*entryRepository).index
+		require.NotNil(t, idx)
+		assert.Len(t, jar1.Cookies(url1), 1)
+		assert.Same(t, idx, repo1.(*entryRepository).index)
+	})
+
+	t.Run("should reload after appended by others", func(t *testing.T) {
+		assert.Len(t, jar2.Cookies(url1), 1)
+		jar2.SetCookies(url1, []*http.Cookie{{Name: "b", Value: "2"}})
+		assert.Len(t, jar1.Cookies(url1), 2)
+	})
+
+	t.Run("should reload after compacted by others", func(t *testing.T) {
+		jar1.SetCookies(url1, []*http.Cookie{{Name: "a", MaxAge: -1}})
+		require.NoError(t, repo1.Compact())
+		jar1.SetCookies(url1, []*http.Cookie{{Name: "c", Value: "3"}})
+		require.NoError(t, repo1.Compact())
+		var names []string
+		for _, i := range jar2.Cookies(url1) {
+			names = append(names, i.Name)
+		}
+		assert.ElementsMatch(t, []string{"b", "c"}, names)
+	})
+
+	t.Run("should reload after removed", func(t *testing.T) {
+		require.NoError(t, os.Remove(filename))
+		assert.Len(t, jar1.Cookies(url1), 0)
+	})
+}
+
+func TestEntryRepositoryCompaction(t *testing.T) {
+	var ctx = context.Background()
+	url1, _ := url.Parse("http://example.com")
+	var useJar = func(t *testing.T, options ...Option) (cookiejar.Jar, EntryRepository) {
+		var clock = test_util.NewClock(time.Now())
+		var repo = NewEntryRepository(path.Join(t.TempDir(), "cookies.jsonl"), append(options, OptionClock(clock))...)
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo), cookiejar.OptionClock(clock))
+		require.NoError(t, err)
+		return jar, repo
+	}
+
+	t.Run("should report stats", func(t *testing.T) {
+		var jar, repo = useJar(t)
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}})
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "3"}})
+		jar.SetCookies(url1, []*http.Cookie{{Name: "b", MaxAge: -1}})
+		stats, err := repo.Stats()
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.Entries)
+		assert.Equal(t, 4, stats.Lines)
+		assert.Equal(t, 1, stats.Tombstones)
+		assert.Equal(t, 3, stats.Dead())
+		info, err := os.Stat(repo.Filename())
+		require.NoError(t, err)
+		assert.Equal(t, info.Size(), stats.Bytes)
+
+		require.NoError(t, repo.Compact())
+		stats, err = repo.Stats()
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.Lines)
+		assert.Equal(t, 0, stats.Tombstones)
+	})
+
+	t.Run("should compact by ratio", func(t *testing.T) {
+		var jar, repo = useJar(t, OptionCompactRatio(1))
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}})
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "3"}, {Name: "b", Value: "4"}})
+		stats, err := repo.Stats()
+		require.NoError(t, err)
+		assert.Equal(t, 4, stats.Lines)
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "5"}})
+		stats, err = repo.Stats()
+		require.NoError(t, err)
+		assert.Equal(t, 2, stats.Lines)
+		assert.Len(t, jar.Cookies(url1), 2)
+	})
+
+	t.Run("should compact by size", func(t *testing.T) {
+		var jar, repo = useJar(t, OptionCompactSize(1024))
+		for i := 0; i < 20; i++ {
+			jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: strings.Repeat("v", i)}})
+		}
+		stats, err := repo.Stats()
+		require.NoError(t, err)
+		assert.LessOrEqual(t, stats.Bytes, int64(1024))
+		assert.Less(t, stats.Lines, 20)
+	})
+
+	t.Run("should compact by interval", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var jar, repo = useJar(t, OptionCompactInterval(ctx, 10*time.Millisecond))
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "2"}})
+		assert.Eventually(t, func() bool {
+			stats, err := repo.Stats()
+			return err == nil && stats.Lines == 1
+		}, time.Second, 10*time.Millisecond)
+	})
+}
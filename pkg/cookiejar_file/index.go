@@ -0,0 +1,100 @@
+package cookiejar_file
+
+import (
+	"os"
+)
+
+// index holds entries of the file in memory, keyed by jar key then id.
+type index struct {
+	m       map[string]map[string]entry
+	keyByID map[string]string
+	// lines is count of applied records.
+	lines int
+	// tombstones is count of applied deletion records.
+	tombstones int
+}
+
+func newIndex() *index {
+	return &index{
+		m:       make(map[string]map[string]entry),
+		keyByID: make(map[string]string),
+	}
+}
+
+// apply a record in the file, records should be applied in file order.
+func (idx *index) apply(i entry) {
+	idx.lines++
+	if !newNullTime(i.Deleted).IsNull() {
+		idx.tombstones++
+		var key, ok = idx.keyByID[i.ID]
+		if !ok {
+			return
+		}
+		var m = idx.m[key]
+		delete(m, i.ID)
+		if len(m) == 0 {
+			delete(idx.m, key)
+		}
+		delete(idx.keyByID, i.ID)
+		return
+	}
+	if !newNullTime(i.Touched).IsNull() {
+		var m = idx.m[idx.keyByID[i.ID]]
+		if old, ok := m[i.ID]; ok {
+			old.LastAccess = i.Touched
+			m[i.ID] = old
+		}
+		return
+	}
+	var m = idx.m[i.Key]
+	if m == nil {
+		m = make(map[string]entry)
+		idx.m[i.Key] = m
+	}
+	if old, ok := m[i.ID]; ok {
+		i.Creation = old.Creation
+		i.Order = old.Order
+	}
+	m[i.ID] = i
+	idx.keyByID[i.ID] = i.Key
+}
+
+// entries returns count of live entries.
+func (idx *index) entries() int {
+	return len(idx.keyByID)
+}
+
+// compacted resets record counts after the file rewritten with live entries.
+func (idx *index) compacted() {
+	idx.lines = idx.entries()
+	idx.tombstones = 0
+}
+
+// find returns a copy of entries under key.
+func (idx *index) find(key string) []entry {
+	var m = idx.m[key]
+	var ret = make([]entry, 0, len(m))
+	for _, i := range m {
+		ret = append(ret, i)
+	}
+	return ret
+}
+
+// findAll returns a copy of all entries.
+func (idx *index) findAll() []entry {
+	var ret = make([]entry, 0, len(idx.keyByID))
+	for _, m := range idx.m {
+		for _, i := range m {
+			ret = append(ret, i)
+		}
+	}
+	return ret
+}
+
+// sameFileState reports whether file is unchanged since a was taken,
+// by comparing inode, size and modification time.
+func sameFileState(a, b os.FileInfo) bool {
+	return os.SameFile(a, b) &&
+		a.Size() == b.Size() &&
+		a.ModTime().Equal(b.ModTime())
+}
@@ -0,0 +1,67 @@
+package cookiejar_file
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// ErrLockTimeout is returned when the lock file can not be acquired
+// within the lock timeout.
+var ErrLockTimeout = errors.New("cookiejar_file: lock timeout")
+
+const lockSuffix = ".lock"
+
+// lockFile acquires advisory lock on `<filename>.lock`,
+// shared for reading and exclusive for writing.
+// Reading also creates the lock file so it can not race the first writer,
+// it only proceeds without lock when the directory not exists or is not
+// writable, since no writer can create the file there.
+func (r *entryRepository) lockFile(exclusive bool) (unlock func(), err error) {
+	if r.lockTimeout < 0 {
+		return func() {}, nil
+	}
+	var name = r.filename + lockSuffix
+	var f *os.File
+	if exclusive {
+		f, err = os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0600)
+	} else {
+		f, err = os.OpenFile(name, os.O_RDONLY|os.O_CREATE, 0600)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			// directory not writable, use existing lock file only
+			f, err = os.Open(name)
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			return func() {}, nil
+		}
+	}
+	if err != nil {
+		return
+	}
+	var deadline = time.Now().Add(r.lockTimeout)
+	var delay = time.Millisecond
+	for {
+		var ok bool
+		ok, err = tryLock(f, exclusive)
+		if err != nil {
+			f.Close()
+			return
+		}
+		if ok {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			f.Close()
+			err = ErrLockTimeout
+			return
+		}
+		time.Sleep(delay)
+		if delay < 50*time.Millisecond {
+			delay *= 2
+		}
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package cookiejar_file
+
+import (
+	"os"
+)
+
+// tryLock is a no-op on platforms without flock,
+// only in-process locking is available.
+func tryLock(f *os.File, exclusive bool) (ok bool, err error) {
+	return true, nil
+}
+
+func unlockFile(f *os.File) {}
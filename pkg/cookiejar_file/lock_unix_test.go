@@ -0,0 +1,113 @@
+//go:build unix
+
+package cookiejar_file
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLock(t *testing.T) {
+	var ctx = context.Background()
+
+	t.Run("should timeout", func(t *testing.T) {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		var repo = NewEntryRepository(filename, OptionLockTimeout(20*time.Millisecond))
+		f, err := os.OpenFile(filename+lockSuffix, os.O_RDWR|os.O_CREATE, 0600)
+		require.NoError(t, err)
+		defer f.Close()
+		require.NoError(t, syscall.Flock(int(f.Fd()), syscall.LOCK_EX))
+
+		err = repo.Compact()
+		assert.ErrorIs(t, err, ErrLockTimeout)
+		err = repo.FindAll(ctx).ForEach(func(i cookiejar.Entry) (err error) { return })
+		assert.ErrorIs(t, err, ErrLockTimeout)
+
+		require.NoError(t, syscall.Flock(int(f.Fd()), syscall.LOCK_UN))
+		assert.NoError(t, repo.Compact())
+	})
+
+	t.Run("should allow shared read", func(t *testing.T) {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		var repo = NewEntryRepository(filename, OptionLockTimeout(20*time.Millisecond))
+		f, err := os.OpenFile(filename+lockSuffix, os.O_RDWR|os.O_CREATE, 0600)
+		require.NoError(t, err)
+		defer f.Close()
+		require.NoError(t, syscall.Flock(int(f.Fd()), syscall.LOCK_SH))
+
+		_, err = repo.Stats()
+		assert.NoError(t, err)
+	})
+
+	t.Run("should create lock file for reading", func(t *testing.T) {
+		var dir = path.Join(t.TempDir(), "missing")
+		var filename = path.Join(dir, "cookies.jsonl")
+		var repo = NewEntryRepository(filename)
+		var count int
+		require.NoError(t, repo.FindAll(ctx).ForEach(func(i cookiejar.Entry) (err error) {
+			count++
+			return
+		}))
+		assert.Equal(t, 0, count)
+		_, err := os.Stat(dir)
+		assert.True(t, os.IsNotExist(err))
+
+		require.NoError(t, os.Mkdir(dir, 0700))
+		require.NoError(t, repo.Find(ctx, "example.com").ForEach(func(i cookiejar.Entry) (err error) { return }))
+		_, err = os.Stat(filename + lockSuffix)
+		assert.NoError(t, err)
+	})
+
+	t.Run("should read without lock file in read-only dir", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("root can write read-only dir")
+		}
+		var dir = t.TempDir()
+		var filename = path.Join(dir, "cookies.jsonl")
+		require.NoError(t, os.Chmod(dir, 0500))
+		t.Cleanup(func() { os.Chmod(dir, 0700) })
+		var repo = NewEntryRepository(filename)
+		require.NoError(t, repo.Find(ctx, "example.com").ForEach(func(i cookiejar.Entry) (err error) { return }))
+		_, err := os.Stat(filename + lockSuffix)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("should not lose appends during compaction", func(t *testing.T) {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		u, _ := url.Parse("http://example.com")
+		var wg sync.WaitGroup
+		for worker := 0; worker < 4; worker++ {
+			var repo = NewEntryRepository(filename)
+			jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo))
+			require.NoError(t, err)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 20; i++ {
+					jar.SetCookies(u, []*http.Cookie{{Name: fmt.Sprintf("%d-%d", worker, i), Value: "1", MaxAge: 3600}})
+					if i%5 == 0 {
+						assert.NoError(t, repo.Compact())
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		var repo = NewEntryRepository(filename)
+		stats, err := repo.Stats()
+		require.NoError(t, err)
+		assert.Equal(t, 80, stats.Entries)
+	})
+}
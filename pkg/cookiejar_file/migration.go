@@ -0,0 +1,83 @@
+package cookiejar_file
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// formatVersion is the version written to file header,
+// files without header are version 0.
+const formatVersion = 1
+
+// ErrUnsupportedVersion is returned when the file is written by a newer
+// version of this package.
+var ErrUnsupportedVersion = errors.New("cookiejar_file: unsupported file version")
+
+// header is the first line of the file.
+type header struct {
+	Version int `json:"version"`
+}
+
+// parseHeader returns ok false when line is not a header.
+func parseHeader(line []byte) (version int, ok bool) {
+	var v struct {
+		Version *int   `json:"version"`
+		ID      string `json:"id"`
+	}
+	if json.Unmarshal(line, &v) != nil || v.Version == nil || v.ID != "" {
+		return
+	}
+	return *v.Version, true
+}
+
+// migration upgrades a record of file version `from` to `from + 1`.
+type migration struct {
+	from    int
+	migrate func(record map[string]json.RawMessage) (err error)
+}
+
+var migrations = []migration{
+	{
+		// 0.2.0 renamed `creationIndex` to `order`.
+		from: 0,
+		migrate: func(record map[string]json.RawMessage) (err error) {
+			if v, ok := record["creationIndex"]; ok {
+				if _, ok := record["order"]; !ok {
+					record["order"] = v
+				}
+				delete(record, "creationIndex")
+			}
+			return
+		},
+	},
+}
+
+// decodeRecord decodes line written in version,
+// migrations are applied when version is older than formatVersion.
+func decodeRecord(line []byte, version int) (i entry, err error) {
+	if version == formatVersion {
+		err = json.Unmarshal(line, &i)
+		return
+	}
+	var record map[string]json.RawMessage
+	err = json.Unmarshal(line, &record)
+	if err != nil {
+		return
+	}
+	for _, m := range migrations {
+		if m.from < version {
+			continue
+		}
+		err = m.migrate(record)
+		if err != nil {
+			return i, fmt.Errorf("migrate from version %d: %w", m.from, err)
+		}
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &i)
+	return
+}
@@ -0,0 +1,74 @@
+package cookiejar_file
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigration(t *testing.T) {
+	var ctx = context.Background()
+	url1, _ := url.Parse("http://example.com")
+	var orders = func(t *testing.T, repo EntryRepository) (ret map[string]int) {
+		ret = make(map[string]int)
+		require.NoError(t, repo.FindAll(ctx).ForEach(func(i cookiejar.Entry) (err error) {
+			ret[i.Name()] = i.Order()
+			return
+		}))
+		return
+	}
+
+	t.Run("should write header", func(t *testing.T) {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(NewEntryRepository(filename)))
+		require.NoError(t, err)
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		data, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(data), "{\"version\":1}\n"))
+	})
+
+	t.Run("should migrate legacy file", func(t *testing.T) {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		require.NoError(t, os.WriteFile(filename, []byte(strings.Join([]string{
+			`{"id":"example.com;example.com;/;a","key":"example.com","name":"a","value":"1","domain":"example.com","path":"/","hostOnly":true,"creation":"2021-01-01T00:00:00Z"}`,
+			`{"id":"example.com;example.com;/;b","key":"example.com","name":"b","value":"2","domain":"example.com","path":"/","hostOnly":true,"creation":"2021-01-01T00:00:00Z","creationIndex":1}`,
+			"",
+		}, "\n")), 0600))
+		var repo = NewEntryRepository(filename)
+		assert.Equal(t, map[string]int{"a": 0, "b": 1}, orders(t, repo))
+
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo))
+		require.NoError(t, err)
+		jar.SetCookies(url1, []*http.Cookie{{Name: "c", Value: "3"}})
+		data, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(data), "{\"version\":1}\n"))
+		assert.NotContains(t, string(data), "creationIndex")
+		var got = orders(t, NewEntryRepository(filename))
+		assert.Len(t, got, 3)
+		assert.Equal(t, 1, got["b"])
+	})
+
+	t.Run("should reject newer version", func(t *testing.T) {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		require.NoError(t, os.WriteFile(filename, []byte("{\"version\":999}\n"), 0600))
+		var repo = NewEntryRepository(filename)
+		var err = repo.FindAll(ctx).ForEach(func(i cookiejar.Entry) (err error) { return })
+		assert.True(t, errors.Is(err, ErrUnsupportedVersion))
+		err = repo.Compact()
+		assert.True(t, errors.Is(err, ErrUnsupportedVersion))
+		data, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		assert.Equal(t, "{\"version\":999}\n", string(data))
+	})
+}
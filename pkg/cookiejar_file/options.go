@@ -0,0 +1,179 @@
+package cookiejar_file
+
+import (
+	"context"
+	"time"
+
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+)
+
+// Options are the options for creating a new EntryRepository.
+type Options struct {
+	clock              cookiejar.Clock
+	lockTimeout        time.Duration
+	compactRatio       float64
+	compactSize        int64
+	compactInterval    time.Duration
+	compactIntervalCtx context.Context
+	onCompactError     func(err error)
+	onMalformedLine    func(err *MalformedLineError)
+	durability         Durability
+	keyProvider        KeyProvider
+	allowPlaintext     bool
+	compression        bool
+	shardCacheSize     int
+}
+
+type Option func(opts *Options)
+
+// OptionClock defines time source of the repository,
+// defaults to cookiejar.SystemClock().
+func OptionClock(v cookiejar.Clock) Option {
+	if v == nil {
+		panic("nil clock")
+	}
+	return func(opts *Options) {
+		opts.clock = v
+	}
+}
+
+// OptionLockTimeout defines how long to wait for the `<filename>.lock`
+// advisory lock that guards the file across processes, operations return
+// ErrLockTimeout when exceeded. Negative value disables the lock file.
+// Defaults to 10 seconds. The lock is a no-op on non-unix platforms.
+func OptionLockTimeout(v time.Duration) Option {
+	return func(opts *Options) {
+		opts.lockTimeout = v
+	}
+}
+
+// OptionCompactRatio compacts the file after a write when dead lines
+// (overwritten entries, tombstones and touch records) exceed v times of
+// live entries.
+func OptionCompactRatio(v float64) Option {
+	if v <= 0 {
+		panic("compact ratio must be positive")
+	}
+	return func(opts *Options) {
+		opts.compactRatio = v
+	}
+}
+
+// OptionCompactSize compacts the file after a write when file size
+// exceeds v bytes and there are dead lines.
+func OptionCompactSize(v int64) Option {
+	if v <= 0 {
+		panic("compact size must be positive")
+	}
+	return func(opts *Options) {
+		opts.compactSize = v
+	}
+}
+
+// OptionCompactInterval compacts the file in background every d
+// when there are dead lines, until ctx done.
+func OptionCompactInterval(ctx context.Context, d time.Duration) Option {
+	if ctx == nil {
+		panic("nil context")
+	}
+	if d <= 0 {
+		panic("compact interval must be positive")
+	}
+	return func(opts *Options) {
+		opts.compactIntervalCtx = ctx
+		opts.compactInterval = d
+	}
+}
+
+// OptionOnCompactError handles error of automatic compaction,
+// defaults to ignore since the file is still valid without compaction.
+func OptionOnCompactError(cb func(err error)) Option {
+	if cb == nil {
+		panic("nil callback")
+	}
+	return func(opts *Options) {
+		opts.onCompactError = cb
+	}
+}
+
+// OptionOnMalformedLine enables recovery mode, malformed lines in the file
+// are skipped and reported to cb instead of failing every read.
+// A torn trailing line left by interrupted append is truncated on next write.
+//
+// Without this option, reading a malformed line returns *MalformedLineError.
+func OptionOnMalformedLine(cb func(err *MalformedLineError)) Option {
+	if cb == nil {
+		panic("nil callback")
+	}
+	return func(opts *Options) {
+		opts.onMalformedLine = cb
+	}
+}
+
+// OptionDurability defines when to fsync, defaults to DurabilityOnCompact.
+func OptionDurability(v Durability) Option {
+	return func(opts *Options) {
+		opts.durability = v
+	}
+}
+
+// OptionEncryption encrypts entry values with AES-GCM using keys from p.
+// Compact re-encrypts all records with current key, so old keys can be
+// retired after compaction. Records that can not be authenticated fail the
+// read with ErrUnauthenticatedRecord, Repair drops them.
+//
+// Only values are encrypted, names and domains are kept in plaintext since
+// they form record ids.
+func OptionEncryption(p KeyProvider) Option {
+	if p == nil {
+		panic("nil key provider")
+	}
+	return func(opts *Options) {
+		opts.keyProvider = p
+	}
+}
+
+// OptionAllowPlaintext accepts plaintext records when encryption enabled,
+// used to migrate an existing file, call Compact to encrypt them.
+func OptionAllowPlaintext() Option {
+	return func(opts *Options) {
+		opts.allowPlaintext = true
+	}
+}
+
+// OptionCompression makes Compact write entries into gzip compressed
+// `<filename>.gz`, appends still go to the uncompressed file.
+// The compressed file is always read when exists, so this option can be
+// turned off later, next Compact converts back to uncompressed file.
+func OptionCompression() Option {
+	return func(opts *Options) {
+		opts.compression = true
+	}
+}
+
+// OptionShardCacheSize defines how many shards NewShardedEntryRepository
+// keeps in memory, least recently used idle shards are dropped and loaded
+// from file again on next use. Defaults to 256.
+// Shards are never dropped when lock file is disabled by OptionLockTimeout,
+// since two instances of a shard can only coordinate through the lock.
+func OptionShardCacheSize(v int) Option {
+	if v <= 0 {
+		panic("shard cache size must be positive")
+	}
+	return func(opts *Options) {
+		opts.shardCacheSize = v
+	}
+}
+
+func newOptions(options ...Option) *Options {
+	var opts = new(Options)
+	opts.clock = cookiejar.SystemClock()
+	opts.lockTimeout = 10 * time.Second
+	opts.durability = DurabilityOnCompact
+	opts.onCompactError = func(err error) {}
+	opts.shardCacheSize = 256
+	for _, i := range options {
+		i(opts)
+	}
+	return opts
+}
@@ -0,0 +1,154 @@
+package cookiejar_file
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// MalformedLineError describes a line in the file that is not a valid record.
+type MalformedLineError struct {
+	// Line is 1-based line number.
+	Line int
+	// Offset is byte offset of the line start.
+	Offset int64
+	// Torn is true when the line is the last line without line break,
+	// which is usually caused by interrupted append.
+	Torn bool
+	Err  error
+}
+
+func (e *MalformedLineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *MalformedLineError) Unwrap() error {
+	return e.Err
+}
+
+// tail describes state of the file after read.
+type tail struct {
+	// version is format version of the file.
+	version int
+	// torn is offset of a skipped torn line, -1 if none.
+	torn int64
+	// unterminated is true when the last line has no line break.
+	unterminated bool
+}
+
+// readLines calls cb with each line without line break,
+// unlike bufio.Scanner there is no line length limit.
+func readLines(rd io.Reader, cb func(line []byte, number int, offset int64, terminated bool) (err error)) (err error) {
+	var br = bufio.NewReader(rd)
+	var offset int64
+	for number := 1; ; number++ {
+		line, readErr := br.ReadBytes('\n')
+		if len(line) > 0 {
+			var size = int64(len(line))
+			var terminated = line[len(line)-1] == '\n'
+			if terminated {
+				line = line[:len(line)-1]
+			}
+			err = cb(line, number, offset, terminated)
+			if err != nil {
+				return
+			}
+			offset += size
+		}
+		if readErr == io.EOF {
+			return
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+// salvageReader ends rd at the first read error instead of failing,
+// so lines before a corrupted part can still be read.
+type salvageReader struct {
+	rd     io.Reader
+	offset int64
+	lines  int
+	err    error
+}
+
+func (s *salvageReader) Read(p []byte) (n int, err error) {
+	n, err = s.rd.Read(p)
+	s.offset += int64(n)
+	s.lines += bytes.Count(p[:n], []byte{'\n'})
+	if err != nil && err != io.EOF {
+		s.err = err
+		err = io.EOF
+	}
+	return
+}
+
+// malformed describes the read error as a line error, nil if no error.
+func (s *salvageReader) malformed() *MalformedLineError {
+	if s.err == nil {
+		return nil
+	}
+	return &MalformedLineError{
+		Line:   s.lines + 1,
+		Offset: s.offset,
+		Err:    s.err,
+	}
+}
+
+// readRecords calls cb with each record in rd. Malformed line is passed to
+// onMalformed and skipped, or returned as *MalformedLineError when
+// onMalformed is nil.
+func readRecords(rd io.Reader, cb func(i entry) (err error), onMalformed func(err *MalformedLineError)) (t tail, err error) {
+	return readCheckedRecords(rd, nil, cb, onMalformed)
+}
+
+// readCheckedRecords is readRecords that also treats record as malformed
+// when check returns error.
+func readCheckedRecords(rd io.Reader, check func(i entry) (err error), cb func(i entry) (err error), onMalformed func(err *MalformedLineError)) (t tail, err error) {
+	t.torn = -1
+	t.version = -1
+	err = readLines(rd, func(line []byte, number int, offset int64, terminated bool) (err error) {
+		t.unterminated = !terminated
+		if len(bytes.TrimSpace(line)) == 0 {
+			return
+		}
+		if t.version < 0 {
+			if v, ok := parseHeader(line); ok {
+				if v > formatVersion {
+					return fmt.Errorf("%w: file version %d is newer than supported version %d", ErrUnsupportedVersion, v, formatVersion)
+				}
+				t.version = v
+				return
+			}
+			t.version = 0
+		}
+		i, err := decodeRecord(line, t.version)
+		if err == nil && check != nil {
+			err = check(i)
+		}
+		if err != nil {
+			var malformed = &MalformedLineError{
+				Line:   number,
+				Offset: offset,
+				Torn:   !terminated,
+				Err:    err,
+			}
+			if onMalformed == nil {
+				return malformed
+			}
+			onMalformed(malformed)
+			if malformed.Torn {
+				t.torn = offset
+			}
+			return nil
+		}
+		return cb(i)
+	})
+	if t.version < 0 {
+		// empty file
+		t.version = formatVersion
+	}
+	return
+}
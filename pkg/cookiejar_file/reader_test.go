@@ -0,0 +1,109 @@
+package cookiejar_file
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecovery(t *testing.T) {
+	var ctx = context.Background()
+	url1, _ := url.Parse("http://example.com")
+	var useFile = func(t *testing.T, tail string) string {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(NewEntryRepository(filename)))
+		require.NoError(t, err)
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0600)
+		require.NoError(t, err)
+		_, err = f.WriteString(tail)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		return filename
+	}
+	var names = func(t *testing.T, repo EntryRepository) (ret []string) {
+		require.NoError(t, repo.FindAll(ctx).ForEach(func(i cookiejar.Entry) (err error) {
+			ret = append(ret, i.Name())
+			return
+		}))
+		return
+	}
+
+	t.Run("should report line number", func(t *testing.T) {
+		var filename = useFile(t, `{"id":"example.com;example.com;/;b","key":"exa`)
+		var err = NewEntryRepository(filename).FindAll(ctx).ForEach(func(i cookiejar.Entry) (err error) { return })
+		var malformed *MalformedLineError
+		require.True(t, errors.As(err, &malformed))
+		assert.Equal(t, 3, malformed.Line)
+		assert.True(t, malformed.Torn)
+	})
+
+	t.Run("should skip malformed lines", func(t *testing.T) {
+		var filename = useFile(t, "not json\n{\"id\":\"trunc")
+		var reported []*MalformedLineError
+		var repo = NewEntryRepository(filename, OptionOnMalformedLine(func(err *MalformedLineError) {
+			reported = append(reported, err)
+		}))
+		assert.Equal(t, []string{"a"}, names(t, repo))
+		require.Len(t, reported, 2)
+		assert.Equal(t, 3, reported[0].Line)
+		assert.False(t, reported[0].Torn)
+		assert.Equal(t, 4, reported[1].Line)
+		assert.True(t, reported[1].Torn)
+
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo))
+		require.NoError(t, err)
+		jar.SetCookies(url1, []*http.Cookie{{Name: "b", Value: "2"}})
+		data, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "trunc")
+
+		reported = nil
+		assert.ElementsMatch(t, []string{"a", "b"}, names(t, NewEntryRepository(filename, OptionOnMalformedLine(func(err *MalformedLineError) {
+			reported = append(reported, err)
+		}))))
+		assert.Len(t, reported, 1)
+	})
+
+	t.Run("should terminate valid last line before append", func(t *testing.T) {
+		var filename = useFile(t, `{"id":"example.com;example.com;/;b","key":"example.com","name":"b","domain":"example.com","path":"/","hostOnly":true}`)
+		var repo = NewEntryRepository(filename)
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo))
+		require.NoError(t, err)
+		jar.SetCookies(url1, []*http.Cookie{{Name: "c", Value: "3"}})
+		assert.ElementsMatch(t, []string{"a", "b", "c"}, names(t, NewEntryRepository(filename)))
+	})
+
+	t.Run("should repair", func(t *testing.T) {
+		var filename = useFile(t, "not json\n\n{\"id\":\"trunc")
+		var count int
+		var repo = NewEntryRepository(filename, OptionOnMalformedLine(func(err *MalformedLineError) {
+			count++
+		}))
+		require.NoError(t, repo.Repair())
+		assert.Equal(t, 2, count)
+		assert.Equal(t, []string{"a"}, names(t, NewEntryRepository(filename)))
+	})
+
+	t.Run("should read long line", func(t *testing.T) {
+		var filename = path.Join(t.TempDir(), "cookies.jsonl")
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(NewEntryRepository(filename)))
+		require.NoError(t, err)
+		var value = strings.Repeat("v", 128*1024)
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: value}})
+		var repo = NewEntryRepository(filename)
+		require.NoError(t, repo.FindAll(ctx).ForEach(func(i cookiejar.Entry) (err error) {
+			assert.Equal(t, value, i.Value())
+			return
+		}))
+	})
+}
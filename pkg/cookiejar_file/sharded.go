@@ -0,0 +1,433 @@
+package cookiejar_file
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NateScarlet/cookiejar/internal/util"
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+)
+
+// ShardedEntryRepository stores entries of each jar key in its own file
+// inside a directory, so operations on one site only touch one file.
+type ShardedEntryRepository interface {
+	cookiejar.EntryRepository
+	cookiejar.EntryLister
+	cookiejar.EntryToucher
+	// Shard returns repository of the file that stores entries of key,
+	// it can be used to compact or repair single shard.
+	Shard(key string) EntryRepository
+	// DeleteKey removes all entries of key by removing its file.
+	DeleteKey(ctx context.Context, key string) (err error)
+	// Compact compacts every shard.
+	Compact() (err error)
+	// Repair repairs every shard.
+	Repair() (err error)
+	// Stats returns sum of every shard.
+	Stats() (Stats, error)
+	Dir() string
+}
+
+const shardSuffix = ".jsonl"
+
+// shardName escapes key into a portable file name,
+// only lower case letters, digits, `-` and non-leading `.` are kept.
+func shardName(key string) string {
+	if key == "" {
+		return "_"
+	}
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		var c = key[i]
+		if 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' && i > 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// parseShardName reverses shardName, ok is false when name is not a shard.
+func parseShardName(name string) (key string, ok bool) {
+	if name == "_" {
+		return "", true
+	}
+	key, err := url.PathUnescape(name)
+	if err != nil || shardName(key) != name {
+		return "", false
+	}
+	return key, true
+}
+
+// keyOfID returns jar key part of Entry.ID().
+func keyOfID(id string) string {
+	key, _, _ := strings.Cut(id, ";")
+	return key
+}
+
+type shardedEntryRepository struct {
+	dir  string
+	opts *Options
+
+	mu sync.Mutex
+	// shards maps jar key to element of lru.
+	shards map[string]*list.Element
+	// lru holds cached *shard, most recently used first.
+	lru *list.List
+}
+
+type shard struct {
+	key  string
+	repo *entryRepository
+}
+
+func (r *shardedEntryRepository) shard(key string) *entryRepository {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, ok := r.shards[key]; ok {
+		r.lru.MoveToFront(e)
+		return e.Value.(*shard).repo
+	}
+	var v = newEntryRepository(filepath.Join(r.dir, shardName(key)+shardSuffix), r.opts)
+	r.shards[key] = r.lru.PushFront(&shard{key, v})
+	r.evict()
+	return v
+}
+
+// evict drops least recently used shards that exceed cache size,
+// shards in use or with pending group commit are kept, so the cache may
+// exceed the size temporarily. A dropped shard still in use by others
+// stays valid since the file lock guards it like another process, so
+// nothing is evicted when the lock file is disabled.
+// caller should hold the lock.
+func (r *shardedEntryRepository) evict() {
+	if r.opts.lockTimeout < 0 {
+		return
+	}
+	for e := r.lru.Back(); e != nil && r.lru.Len() > r.opts.shardCacheSize; {
+		var prev = e.Prev()
+		var s = e.Value.(*shard)
+		if s.repo.idle() {
+			r.lru.Remove(e)
+			delete(r.shards, s.key)
+		}
+		e = prev
+	}
+}
+
+// keys lists jar keys that have a file in the directory.
+func (r *shardedEntryRepository) keys() (ret []string, err error) {
+	files, err := os.ReadDir(r.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return
+	}
+	var seen = make(map[string]struct{})
+	for _, i := range files {
+		if i.IsDir() {
+			continue
+		}
+		var name = strings.TrimSuffix(i.Name(), baseSuffix)
+		name, ok := strings.CutSuffix(name, shardSuffix)
+		if !ok {
+			continue
+		}
+		key, ok := parseShardName(name)
+		if !ok {
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		ret = append(ret, key)
+	}
+	sort.Strings(ret)
+	return
+}
+
+// forEachShard calls cb with every shard in the directory.
+func (r *shardedEntryRepository) forEachShard(cb func(key string, s *entryRepository) (err error)) (err error) {
+	keys, err := r.keys()
+	if err != nil {
+		return
+	}
+	for _, key := range keys {
+		err = cb(key, r.shard(key))
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// groupByKey groups entry ids by jar key.
+func groupByKey(id []string) map[string][]string {
+	var ret = make(map[string][]string)
+	for _, i := range id {
+		var key = keyOfID(i)
+		ret[key] = append(ret[key], i)
+	}
+	return ret
+}
+
+func (r *shardedEntryRepository) mkdir() (err error) {
+	return os.MkdirAll(r.dir, 0700)
+}
+
+// compactEvery compacts shards that have dead lines on each tick,
+// until ctx done.
+func (r *shardedEntryRepository) compactEvery(ctx context.Context, interval time.Duration) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		var err = r.forEachShard(func(key string, s *entryRepository) (err error) {
+			err = s.compactDead()
+			if err != nil {
+				r.opts.onCompactError(fmt.Errorf("cookiejar_file: shardedEntryRepository.compactEvery('%s'): %w", key, err))
+			}
+			return nil
+		})
+		if err != nil {
+			r.opts.onCompactError(fmt.Errorf("cookiejar_file: shardedEntryRepository.compactEvery: %w", err))
+		}
+	}
+}
+
+// Find implements EntryRepository,
+// only the file of key is read.
+func (r *shardedEntryRepository) Find(ctx context.Context, key string) cookiejar.EntryIterator {
+	return cookiejar.EntryIteratorFunc(func(cb func(i cookiejar.Entry) (err error)) (err error) {
+		return r.shard(key).Find(ctx, key).ForEach(cb)
+	})
+}
+
+// FindAll implements EntryLister
+func (r *shardedEntryRepository) FindAll(ctx context.Context) cookiejar.EntryIterator {
+	return cookiejar.EntryIteratorFunc(func(cb func(i cookiejar.Entry) (err error)) (err error) {
+		defer func() {
+			if err != nil {
+				err = fmt.Errorf("cookiejar_file: shardedEntryRepository.FindAll: %w", err)
+			}
+		}()
+		return r.forEachShard(func(key string, s *entryRepository) (err error) {
+			return s.FindAll(ctx).ForEach(cb)
+		})
+	})
+}
+
+// Save implements EntryRepository
+func (r *shardedEntryRepository) Save(ctx context.Context, entry cookiejar.Entry) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_file: shardedEntryRepository.Save: %w", err)
+		}
+	}()
+	err = r.mkdir()
+	if err != nil {
+		return
+	}
+	return r.shard(entry.Key()).Save(ctx, entry)
+}
+
+// Delete implements EntryRepository
+func (r *shardedEntryRepository) Delete(ctx context.Context, id string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_file: shardedEntryRepository.Delete('%s'): %w", id, err)
+		}
+	}()
+	return r.DeleteMany(ctx, []string{id})
+}
+
+// DeleteMany implements EntryRepository,
+// ids are deleted from each shard separately.
+func (r *shardedEntryRepository) DeleteMany(ctx context.Context, id []string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_file: shardedEntryRepository.DeleteMany(%s): %w", id, err)
+		}
+	}()
+	err = r.mkdir()
+	if err != nil {
+		return
+	}
+	for key, i := range groupByKey(id) {
+		err = r.shard(key).DeleteMany(ctx, i)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Touch implements EntryToucher
+func (r *shardedEntryRepository) Touch(ctx context.Context, id []string, t time.Time) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_file: shardedEntryRepository.Touch(%s): %w", id, err)
+		}
+	}()
+	err = r.mkdir()
+	if err != nil {
+		return
+	}
+	for key, i := range groupByKey(id) {
+		err = r.shard(key).Touch(ctx, i, t)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Shard implements ShardedEntryRepository
+func (r *shardedEntryRepository) Shard(key string) EntryRepository {
+	return r.shard(key)
+}
+
+// DeleteKey implements ShardedEntryRepository,
+// the lock file is kept since other processes may still hold it.
+func (r *shardedEntryRepository) DeleteKey(ctx context.Context, key string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_file: shardedEntryRepository.DeleteKey('%s'): %w", key, err)
+		}
+	}()
+	var s = r.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = os.Stat(r.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	unlock, err := s.lockFile(true)
+	if err != nil {
+		return
+	}
+	defer unlock()
+	return s.remove()
+}
+
+// Compact implements ShardedEntryRepository
+func (r *shardedEntryRepository) Compact() (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_file: shardedEntryRepository.Compact: %w", err)
+		}
+	}()
+	return r.forEachShard(func(key string, s *entryRepository) (err error) {
+		return s.Compact()
+	})
+}
+
+// Repair implements ShardedEntryRepository
+func (r *shardedEntryRepository) Repair() (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_file: shardedEntryRepository.Repair: %w", err)
+		}
+	}()
+	return r.forEachShard(func(key string, s *entryRepository) (err error) {
+		return s.Repair()
+	})
+}
+
+// Stats implements ShardedEntryRepository
+func (r *shardedEntryRepository) Stats() (ret Stats, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_file: shardedEntryRepository.Stats: %w", err)
+		}
+	}()
+	err = r.forEachShard(func(key string, s *entryRepository) (err error) {
+		v, err := s.Stats()
+		if err != nil {
+			return
+		}
+		ret.Entries += v.Entries
+		ret.Lines += v.Lines
+		ret.Tombstones += v.Tombstones
+		ret.Bytes += v.Bytes
+		return
+	})
+	return
+}
+
+func (r *shardedEntryRepository) Dir() string {
+	return r.dir
+}
+
+// idle reports whether the repository is not in use and has no pending
+// group commit, so it can be dropped without losing sync error.
+func (r *entryRepository) idle() bool {
+	if !r.mu.TryLock() {
+		return false
+	}
+	defer r.mu.Unlock()
+	return !r.syncPending && r.syncErr == nil
+}
+
+// remove deletes the files of the repository,
+// caller should hold the lock.
+func (r *entryRepository) remove() (err error) {
+	for _, name := range []string{r.baseFilename(), r.filename} {
+		err = os.Remove(name)
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		if err != nil {
+			r.index = nil
+			return
+		}
+	}
+	r.index = newIndex()
+	r.info = nil
+	r.baseInfo = nil
+	r.tail = tail{torn: -1, version: formatVersion}
+	r.outdated = false
+	if r.durability.mode != durabilityNone {
+		err = util.SyncDir(filepath.Dir(r.filename))
+	}
+	return
+}
+
+// NewShardedEntryRepository use one file per jar key inside dir to store
+// cookies, dir is created on first write.
+// options are applied to every shard.
+func NewShardedEntryRepository(dir string, options ...Option) ShardedEntryRepository {
+	if dir == "" {
+		panic("empty dir")
+	}
+	var opts = newOptions(options...)
+	var r = &shardedEntryRepository{
+		dir:    dir,
+		opts:   opts,
+		shards: make(map[string]*list.Element),
+		lru:    list.New(),
+	}
+	if opts.compactInterval > 0 {
+		go r.compactEvery(opts.compactIntervalCtx, opts.compactInterval)
+	}
+	return r
+}
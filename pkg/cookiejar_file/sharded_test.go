@@ -0,0 +1,151 @@
+package cookiejar_file
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"testing"
+
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardName(t *testing.T) {
+	for _, key := range []string{"", "_", "example.com", "127.0.0.1", "::1", ".hidden", "Upper", "a%b", "a/b"} {
+		var name = shardName(key)
+		assert.NotContains(t, name, "/")
+		assert.NotContains(t, name, ":")
+		assert.NotEqual(t, '.', name[0])
+		got, ok := parseShardName(name)
+		assert.True(t, ok, key)
+		assert.Equal(t, key, got)
+	}
+	_, ok := parseShardName("Upper")
+	assert.False(t, ok)
+}
+
+func TestShardedEntryRepository(t *testing.T) {
+	var ctx = context.Background()
+	url1, _ := url.Parse("http://example.com")
+	url2, _ := url.Parse("http://www.example.org")
+	var useJar = func(t *testing.T, dir string, options ...Option) (cookiejar.Jar, ShardedEntryRepository) {
+		var repo = NewShardedEntryRepository(dir, options...)
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo))
+		require.NoError(t, err)
+		return jar, repo
+	}
+	var names = func(t *testing.T, it cookiejar.EntryIterator) (ret []string) {
+		require.NoError(t, it.ForEach(func(i cookiejar.Entry) (err error) {
+			ret = append(ret, i.Name())
+			return
+		}))
+		sort.Strings(ret)
+		return
+	}
+	var files = func(t *testing.T, dir string) (ret []string) {
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		for _, i := range entries {
+			ret = append(ret, i.Name())
+		}
+		return
+	}
+
+	t.Run("should store each key in own file", func(t *testing.T) {
+		var dir = path.Join(t.TempDir(), "cookies")
+		var jar, repo = useJar(t, dir, OptionLockTimeout(-1))
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		jar.SetCookies(url2, []*http.Cookie{{Name: "b", Value: "2"}, {Name: "c", Value: "3", Domain: "example.org"}})
+		assert.Equal(t, []string{"example.com.jsonl", "example.org.jsonl"}, files(t, dir))
+		assert.Equal(t, []string{"a"}, names(t, repo.Find(ctx, "example.com")))
+		assert.Equal(t, []string{"b", "c"}, names(t, repo.Find(ctx, "example.org")))
+		assert.Equal(t, []string{"a", "b", "c"}, names(t, NewShardedEntryRepository(dir).FindAll(ctx)))
+		assert.Equal(t, []string{"b", "c"}, names(t, NewEntryRepository(path.Join(dir, "example.org.jsonl")).FindAll(ctx)))
+
+		var cookies = jar.Cookies(url2)
+		require.Len(t, cookies, 2)
+	})
+
+	t.Run("should delete from shard", func(t *testing.T) {
+		var dir = path.Join(t.TempDir(), "cookies")
+		var jar, repo = useJar(t, dir)
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		jar.SetCookies(url2, []*http.Cookie{{Name: "b", Value: "2"}})
+		jar.SetCookies(url2, []*http.Cookie{{Name: "b", MaxAge: -1}})
+		assert.Equal(t, []string{"a"}, names(t, repo.FindAll(ctx)))
+		stats, err := repo.Shard("example.org").Stats()
+		require.NoError(t, err)
+		assert.Equal(t, 2, stats.Dead())
+
+		require.NoError(t, repo.Compact())
+		stats, err = repo.Stats()
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.Entries)
+		assert.Equal(t, 0, stats.Dead())
+	})
+
+	t.Run("should delete key by removing file", func(t *testing.T) {
+		var dir = path.Join(t.TempDir(), "cookies")
+		var jar, repo = useJar(t, dir, OptionLockTimeout(-1))
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		jar.SetCookies(url2, []*http.Cookie{{Name: "b", Value: "2"}})
+		var other = NewShardedEntryRepository(dir, OptionLockTimeout(-1))
+		assert.Equal(t, []string{"b"}, names(t, other.Find(ctx, "example.org")))
+
+		require.NoError(t, repo.DeleteKey(ctx, "example.org"))
+		assert.Equal(t, []string{"example.com.jsonl"}, files(t, dir))
+		assert.Empty(t, names(t, repo.Find(ctx, "example.org")))
+		assert.Empty(t, names(t, other.Find(ctx, "example.org")))
+		assert.Equal(t, []string{"a"}, names(t, repo.FindAll(ctx)))
+		assert.Empty(t, jar.Cookies(url2))
+
+		jar.SetCookies(url2, []*http.Cookie{{Name: "c", Value: "3"}})
+		assert.Equal(t, []string{"c"}, names(t, other.Find(ctx, "example.org")))
+	})
+
+	t.Run("should ignore missing dir", func(t *testing.T) {
+		var dir = path.Join(t.TempDir(), "cookies")
+		var repo = NewShardedEntryRepository(dir)
+		assert.Empty(t, names(t, repo.FindAll(ctx)))
+		assert.Empty(t, names(t, repo.Find(ctx, "example.com")))
+		require.NoError(t, repo.DeleteKey(ctx, "example.com"))
+		require.NoError(t, repo.Compact())
+		_, err := os.Stat(dir)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("should bound shard cache", func(t *testing.T) {
+		var dir = path.Join(t.TempDir(), "cookies")
+		var jar, repo = useJar(t, dir, OptionShardCacheSize(2))
+		url3, _ := url.Parse("http://example.net")
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		jar.SetCookies(url2, []*http.Cookie{{Name: "b", Value: "2"}})
+		jar.SetCookies(url3, []*http.Cookie{{Name: "c", Value: "3"}})
+		var r = repo.(*shardedEntryRepository)
+		assert.Len(t, r.shards, 2)
+		assert.Equal(t, 2, r.lru.Len())
+		assert.NotContains(t, r.shards, "example.com")
+
+		assert.Equal(t, []string{"a"}, names(t, repo.Find(ctx, "example.com")))
+		assert.Contains(t, r.shards, "example.com")
+		assert.NotContains(t, r.shards, "example.org")
+		assert.Equal(t, []string{"a", "b", "c"}, names(t, repo.FindAll(ctx)))
+		assert.Len(t, r.shards, 2)
+	})
+
+	t.Run("should not evict shard without lock file", func(t *testing.T) {
+		var dir = path.Join(t.TempDir(), "cookies")
+		var jar, repo = useJar(t, dir, OptionShardCacheSize(1), OptionLockTimeout(-1))
+		url3, _ := url.Parse("http://example.net")
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		jar.SetCookies(url2, []*http.Cookie{{Name: "b", Value: "2"}})
+		jar.SetCookies(url3, []*http.Cookie{{Name: "c", Value: "3"}})
+		var r = repo.(*shardedEntryRepository)
+		assert.Len(t, r.shards, 3)
+		assert.Equal(t, []string{"a", "b", "c"}, names(t, repo.FindAll(ctx)))
+	})
+}
@@ -0,0 +1,6 @@
+// Package cookiejar_firefox reads and writes entries in `moz_cookies` table of
+// a Firefox profile "cookies.sqlite" database.
+//
+// The caller opens the database with a database/sql SQLite driver of its
+// choice, it is recommended to use a copy since the browser locks the file.
+package cookiejar_firefox
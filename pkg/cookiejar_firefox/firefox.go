@@ -0,0 +1,383 @@
+package cookiejar_firefox
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+	"golang.org/x/net/publicsuffix"
+)
+
+var endOfTime = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+// sessionExpiry is written as expiry of session entries, since Firefox
+// purges rows with expiry 0 as expired.
+var sessionExpiry = endOfTime.Unix()
+
+// nsICookie sameSite values.
+const (
+	sameSiteNone   = 0
+	sameSiteLax    = 1
+	sameSiteStrict = 2
+	sameSiteUnset  = 256
+)
+
+// Schema of `moz_cookies` table, used by EnsureTable.
+const Schema = `
+CREATE TABLE IF NOT EXISTS moz_cookies (
+	id INTEGER PRIMARY KEY,
+	originAttributes TEXT NOT NULL DEFAULT '',
+	name TEXT,
+	value TEXT,
+	host TEXT,
+	path TEXT,
+	expiry INTEGER,
+	lastAccessed INTEGER,
+	creationTime INTEGER,
+	isSecure INTEGER,
+	isHttpOnly INTEGER,
+	inBrowserElement INTEGER DEFAULT 0,
+	sameSite INTEGER DEFAULT 0,
+	rawSameSite INTEGER DEFAULT 0,
+	schemeMap INTEGER DEFAULT 0,
+	CONSTRAINT moz_uniqueid UNIQUE (name, host, path, originAttributes)
+)
+`
+
+// Options are the options for reading and writing Firefox cookies.
+type Options struct {
+	publicSuffixList cookiejar.PublicSuffixList
+	clock            cookiejar.Clock
+	userContextID    int
+}
+
+type Option func(opts *Options)
+
+// OptionPublicSuffixList is used to compute entry key from cookie domain,
+// should be same as the jar that uses the entries.
+//
+// defaults to golang.org/x/net/publicsuffix.List
+func OptionPublicSuffixList(v cookiejar.PublicSuffixList) Option {
+	if v == nil {
+		panic("nil public suffix list")
+	}
+	return func(opts *Options) {
+		opts.publicSuffixList = v
+	}
+}
+
+// OptionClock is used to skip expired entries on import,
+// defaults to cookiejar.SystemClock().
+func OptionClock(v cookiejar.Clock) Option {
+	if v == nil {
+		panic("nil clock")
+	}
+	return func(opts *Options) {
+		opts.clock = v
+	}
+}
+
+// OptionUserContextID selects container (`userContextId` origin attribute)
+// to read from or write to, defaults to 0 (no container).
+func OptionUserContextID(v int) Option {
+	return func(opts *Options) {
+		opts.userContextID = v
+	}
+}
+
+func newOptions(options ...Option) *Options {
+	var opts = new(Options)
+	opts.publicSuffixList = publicsuffix.List
+	opts.clock = cookiejar.SystemClock()
+	for _, i := range options {
+		i(opts)
+	}
+	return opts
+}
+
+// EnsureTable creates `moz_cookies` table if not exists.
+func EnsureTable(ctx context.Context, db *sql.DB) (err error) {
+	_, err = db.ExecContext(ctx, Schema)
+	if err != nil {
+		err = fmt.Errorf("cookiejar_firefox: EnsureTable: %w", err)
+	}
+	return
+}
+
+func fromMicro(v int64) time.Time {
+	return time.UnixMicro(v).UTC()
+}
+
+func parseSameSite(v int64) string {
+	switch v {
+	case sameSiteNone:
+		return "SameSite=None"
+	case sameSiteLax:
+		return "SameSite=Lax"
+	case sameSiteStrict:
+		return "SameSite=Strict"
+	}
+	return ""
+}
+
+func formatSameSite(v string) int64 {
+	switch v {
+	case "SameSite=None":
+		return sameSiteNone
+	case "SameSite=Lax":
+		return sameSiteLax
+	case "SameSite=Strict":
+		return sameSiteStrict
+	}
+	return sameSiteUnset
+}
+
+// Read entries from `moz_cookies` table of db, ordered by creation time.
+// Only entries of selected container are returned,
+// private browsing and first-party isolation entries are skipped since
+// entry can not represent them.
+// Expiry 0 and end of time are treated as session cookie.
+func Read(ctx context.Context, db *sql.DB, options ...Option) cookiejar.EntryIterator {
+	var opts = newOptions(options...)
+	return cookiejar.EntryIteratorFunc(func(cb func(i cookiejar.Entry) (err error)) (err error) {
+		defer func() {
+			if err != nil {
+				err = fmt.Errorf("cookiejar_firefox: Read: %w", err)
+			}
+		}()
+		rows, err := db.QueryContext(ctx, `
+SELECT
+	originAttributes,
+	name,
+	value,
+	host,
+	path,
+	expiry,
+	lastAccessed,
+	creationTime,
+	isSecure,
+	isHttpOnly,
+	sameSite
+FROM moz_cookies
+ORDER BY creationTime, id
+`)
+		if err != nil {
+			return
+		}
+		defer rows.Close()
+		var order int
+		for rows.Next() {
+			var (
+				rawOriginAttributes string
+				name                string
+				value               string
+				host                string
+				path                string
+				expiry              int64
+				lastAccessed        int64
+				creationTime        int64
+				isSecure            bool
+				isHTTPOnly          bool
+				sameSite            int64
+			)
+			err = rows.Scan(
+				&rawOriginAttributes,
+				&name,
+				&value,
+				&host,
+				&path,
+				&expiry,
+				&lastAccessed,
+				&creationTime,
+				&isSecure,
+				&isHTTPOnly,
+				&sameSite,
+			)
+			if err != nil {
+				return
+			}
+			originAttributes, err := parseOriginAttributes(rawOriginAttributes)
+			if err != nil {
+				return err
+			}
+			if originAttributes.userContextID != opts.userContextID ||
+				originAttributes.privateBrowsingID != 0 ||
+				originAttributes.firstPartyDomain != "" {
+				continue
+			}
+			var domain = host
+			var hostOnly = true
+			if strings.HasPrefix(domain, ".") {
+				domain = domain[1:]
+				hostOnly = false
+			}
+			key, err := cookiejar.JarKey(domain, opts.publicSuffixList)
+			if err != nil {
+				return err
+			}
+			var persistent = expiry != 0 && expiry < sessionExpiry
+			var expires = endOfTime
+			if persistent {
+				expires = time.Unix(expiry, 0).UTC()
+			}
+			var creation = fromMicro(creationTime)
+			var lastAccess = creation
+			if lastAccessed != 0 {
+				lastAccess = fromMicro(lastAccessed)
+			}
+			e, err := cookiejar.EntryFromRepository(
+				key,
+				name,
+				value,
+				domain,
+				path,
+				parseSameSite(sameSite),
+				isSecure,
+				isHTTPOnly,
+				persistent,
+				hostOnly,
+				expires,
+				creation,
+				order,
+				cookiejar.EntryOptionPartitionKey(originAttributes.partitionKey),
+				cookiejar.EntryOptionLastAccess(lastAccess),
+			)
+			if err != nil {
+				return err
+			}
+			err = cb(*e)
+			if err != nil {
+				return err
+			}
+			order++
+		}
+		return rows.Err()
+	})
+}
+
+// Import entries from `moz_cookies` table of db into repo.
+// Expired entries are skipped.
+func Import(ctx context.Context, db *sql.DB, repo cookiejar.EntryRepository, options ...Option) (err error) {
+	var now = newOptions(options...).clock.Now()
+	return Read(ctx, db, options...).ForEach(func(i cookiejar.Entry) (err error) {
+		if i.IsExpiredAt(now) {
+			return
+		}
+		return repo.Save(ctx, i)
+	})
+}
+
+// Write entries into `moz_cookies` table of db in a transaction,
+// existing rows with same name, host, path and origin attributes are replaced.
+// Session entries are written with expiry at end of time, so Firefox keeps
+// them and Read returns them as session entries again.
+func Write(ctx context.Context, db *sql.DB, it cookiejar.EntryIterator, options ...Option) (err error) {
+	var opts = newOptions(options...)
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_firefox: Write: %w", err)
+		}
+	}()
+	var entries []cookiejar.Entry
+	err = it.ForEach(func(i cookiejar.Entry) (err error) {
+		entries = append(entries, i)
+		return
+	})
+	if err != nil {
+		return
+	}
+	// keep order with row id.
+	sort.SliceStable(entries, func(i, j int) bool {
+		var a, b = entries[i], entries[j]
+		if !a.Creation().Equal(b.Creation()) {
+			return a.Creation().Before(b.Creation())
+		}
+		return a.Order() < b.Order()
+	})
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, `
+INSERT INTO moz_cookies (
+	originAttributes,
+	name,
+	value,
+	host,
+	path,
+	expiry,
+	lastAccessed,
+	creationTime,
+	isSecure,
+	isHttpOnly,
+	sameSite,
+	rawSameSite,
+	schemeMap
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (name, host, path, originAttributes) DO UPDATE SET
+	value = excluded.value,
+	expiry = excluded.expiry,
+	lastAccessed = excluded.lastAccessed,
+	creationTime = excluded.creationTime,
+	isSecure = excluded.isSecure,
+	isHttpOnly = excluded.isHttpOnly,
+	sameSite = excluded.sameSite,
+	rawSameSite = excluded.rawSameSite,
+	schemeMap = excluded.schemeMap
+`)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	for _, i := range entries {
+		var host = i.Domain()
+		if !i.HostOnly() {
+			host = "." + host
+		}
+		var expiry = sessionExpiry
+		if i.Persistent() {
+			expiry = i.Expires().Unix()
+		}
+		var originAttributes = originAttributes{
+			userContextID: opts.userContextID,
+			partitionKey:  i.PartitionKey(),
+		}
+		// http: 1, https: 2
+		var schemeMap = 1
+		if i.Secure() {
+			schemeMap = 2
+		}
+		var sameSite = formatSameSite(i.SameSite())
+		_, err = stmt.ExecContext(
+			ctx,
+			originAttributes.String(),
+			i.Name(),
+			i.Value(),
+			host,
+			i.Path(),
+			expiry,
+			i.LastAccess().UnixMicro(),
+			i.Creation().UnixMicro(),
+			i.Secure(),
+			i.HttpOnly(),
+			sameSite,
+			sameSite,
+			schemeMap,
+		)
+		if err != nil {
+			return
+		}
+	}
+	return tx.Commit()
+}
+
+// Export all entries in repo into `moz_cookies` table of db.
+func Export(ctx context.Context, db *sql.DB, repo cookiejar.EntryLister, options ...Option) (err error) {
+	return Write(ctx, db, repo.FindAll(ctx), options...)
+}
@@ -0,0 +1,275 @@
+package cookiejar_firefox
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NateScarlet/cookiejar/internal/test_util"
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func useDatabase(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "cookies.sqlite"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, EnsureTable(context.Background(), db))
+	return db
+}
+
+type testCookie struct {
+	originAttributes string
+	host             string
+	name             string
+	value            string
+	path             string
+	expiry           time.Time
+	creation         time.Time
+	isSecure         bool
+	isHTTPOnly       bool
+	sameSite         int
+}
+
+func insertCookie(t *testing.T, db *sql.DB, c testCookie) {
+	var expiry int64
+	if !c.expiry.IsZero() {
+		expiry = c.expiry.Unix()
+	}
+	_, err := db.Exec(`
+INSERT INTO moz_cookies (
+	originAttributes, name, value, host, path, expiry,
+	lastAccessed, creationTime, isSecure, isHttpOnly, sameSite, rawSameSite
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.originAttributes, c.name, c.value, c.host, c.path, expiry,
+		c.creation.UnixMicro(), c.creation.UnixMicro(), c.isSecure, c.isHTTPOnly, c.sameSite, c.sameSite,
+	)
+	require.NoError(t, err)
+}
+
+func collect(t *testing.T, it cookiejar.EntryIterator) (ret []cookiejar.Entry) {
+	require.NoError(t, it.ForEach(func(i cookiejar.Entry) (err error) {
+		ret = append(ret, i)
+		return
+	}))
+	return
+}
+
+func TestImport(t *testing.T) {
+	var ctx = context.Background()
+	var now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var clock = test_util.NewClock(now)
+	var readAll = func(t *testing.T, db *sql.DB, options ...Option) []cookiejar.Entry {
+		var repo = cookiejar.NewInMemoryEntryRepository()
+		require.NoError(t, Import(ctx, db, repo, append(options, OptionClock(clock))...))
+		return collect(t, repo.(cookiejar.EntryLister).FindAll(ctx))
+	}
+
+	t.Run("should map attributes", func(t *testing.T) {
+		var db = useDatabase(t)
+		insertCookie(t, db, testCookie{
+			host:       "www.example.com",
+			name:       "host",
+			value:      "1",
+			path:       "/",
+			creation:   now.Add(-time.Hour),
+			isHTTPOnly: true,
+			sameSite:   sameSiteUnset,
+		})
+		insertCookie(t, db, testCookie{
+			host:     ".example.com",
+			name:     "domain",
+			value:    "2",
+			path:     "/path",
+			creation: now.Add(-time.Minute),
+			expiry:   now.Add(time.Hour),
+			isSecure: true,
+			sameSite: sameSiteStrict,
+		})
+		insertCookie(t, db, testCookie{
+			originAttributes: "^partitionKey=%28https%2Cexample.org%29",
+			host:             "widget.example.net",
+			name:             "partitioned",
+			value:            "3",
+			path:             "/",
+			creation:         now,
+			expiry:           now.Add(time.Hour),
+			isSecure:         true,
+			sameSite:         sameSiteNone,
+		})
+		insertCookie(t, db, testCookie{
+			host:     "www.example.com",
+			name:     "expired",
+			value:    "4",
+			path:     "/",
+			creation: now.Add(-2 * time.Hour),
+			expiry:   now.Add(-time.Hour),
+		})
+
+		var entries = readAll(t, db)
+		require.Len(t, entries, 3)
+		var m = make(map[string]cookiejar.Entry)
+		for _, i := range entries {
+			m[i.Name()] = i
+		}
+
+		var host = m["host"]
+		assert.Equal(t, "example.com", host.Key())
+		assert.Equal(t, "www.example.com", host.Domain())
+		assert.Equal(t, "1", host.Value())
+		assert.True(t, host.HostOnly())
+		assert.True(t, host.HttpOnly())
+		assert.False(t, host.Persistent())
+		assert.Equal(t, "", host.SameSite())
+		assert.Equal(t, now.Add(-time.Hour), host.Creation())
+
+		var domain = m["domain"]
+		assert.Equal(t, "example.com", domain.Domain())
+		assert.Equal(t, "/path", domain.Path())
+		assert.False(t, domain.HostOnly())
+		assert.True(t, domain.Secure())
+		assert.True(t, domain.Persistent())
+		assert.Equal(t, now.Add(time.Hour), domain.Expires())
+		assert.Equal(t, "SameSite=Strict", domain.SameSite())
+
+		var partitioned = m["partitioned"]
+		assert.Equal(t, "example.net", partitioned.Key())
+		assert.Equal(t, "https://example.org", partitioned.PartitionKey())
+		assert.Equal(t, "SameSite=None", partitioned.SameSite())
+	})
+
+	t.Run("should select container", func(t *testing.T) {
+		var db = useDatabase(t)
+		for _, i := range []testCookie{
+			{name: "default"},
+			{name: "container", originAttributes: "^userContextId=2"},
+			{name: "private", originAttributes: "^privateBrowsingId=1"},
+			{name: "first party", originAttributes: "^firstPartyDomain=example.org"},
+		} {
+			i.host = "example.com"
+			i.path = "/"
+			i.creation = now
+			insertCookie(t, db, i)
+		}
+
+		var entries = readAll(t, db)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "default", entries[0].Name())
+
+		entries = readAll(t, db, OptionUserContextID(2))
+		require.Len(t, entries, 1)
+		assert.Equal(t, "container", entries[0].Name())
+	})
+
+	t.Run("should work with jar", func(t *testing.T) {
+		var db = useDatabase(t)
+		insertCookie(t, db, testCookie{
+			host:     ".example.com",
+			name:     "a",
+			value:    "1",
+			path:     "/",
+			creation: now,
+		})
+		var repo = cookiejar.NewInMemoryEntryRepository()
+		require.NoError(t, Import(ctx, db, repo, OptionClock(clock)))
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo), cookiejar.OptionClock(clock))
+		require.NoError(t, err)
+		u, _ := url.Parse("https://www.example.com")
+		assert.Len(t, jar.Cookies(u), 1)
+	})
+}
+
+func TestExport(t *testing.T) {
+	var ctx = context.Background()
+	var now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var clock = test_util.NewClock(now)
+	var u, _ = url.Parse("https://www.example.com/")
+
+	var repo = cookiejar.NewInMemoryEntryRepository()
+	jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo), cookiejar.OptionClock(clock))
+	require.NoError(t, err)
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "a", Value: "1"},
+		{Name: "b", Value: "2", Domain: "example.com", MaxAge: 3600, Secure: true, HttpOnly: true, SameSite: http.SameSiteLaxMode},
+		{Name: "c", Value: "3", Path: "/"},
+	})
+	clock.Add(time.Minute)
+	jar.SetCookiesForRequest(u, cookiejar.RequestContext{
+		TopLevelSite: mustParseURL("https://example.org"),
+	}, []*http.Cookie{
+		{Name: "d", Value: "4", Secure: true, Partitioned: true, SameSite: http.SameSiteNoneMode},
+	})
+
+	var db = useDatabase(t)
+	require.NoError(t, Export(ctx, db, repo.(cookiejar.EntryLister), OptionUserContextID(1)))
+
+	var row struct {
+		host             string
+		originAttributes string
+		expiry           int64
+		sameSite         int
+	}
+	require.NoError(t, db.QueryRow(
+		"SELECT host, originAttributes, expiry, sameSite FROM moz_cookies WHERE name = 'b'",
+	).Scan(&row.host, &row.originAttributes, &row.expiry, &row.sameSite))
+	assert.Equal(t, ".example.com", row.host)
+	assert.Equal(t, "^userContextId=1", row.originAttributes)
+	assert.Equal(t, now.Add(time.Hour).Unix(), row.expiry)
+	assert.Equal(t, sameSiteLax, row.sameSite)
+
+	var sessionExpiryRow int64
+	require.NoError(t, db.QueryRow("SELECT expiry FROM moz_cookies WHERE name = 'a'").Scan(&sessionExpiryRow))
+	assert.Equal(t, endOfTime.Unix(), sessionExpiryRow)
+
+	// export again should replace existing rows
+	require.NoError(t, Export(ctx, db, repo.(cookiejar.EntryLister), OptionUserContextID(1)))
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM moz_cookies").Scan(&count))
+	assert.Equal(t, 4, count)
+
+	var expected = collect(t, repo.(cookiejar.EntryLister).FindAll(ctx))
+	var actual = collect(t, Read(ctx, db, OptionUserContextID(1)))
+	require.Len(t, actual, len(expected))
+	var m = make(map[string]cookiejar.Entry)
+	for _, i := range actual {
+		m[i.ID()] = i
+	}
+	for _, e := range expected {
+		var a, ok = m[e.ID()]
+		require.True(t, ok, e.ID())
+		assert.Equal(t, e.Value(), a.Value())
+		assert.Equal(t, e.HostOnly(), a.HostOnly())
+		assert.Equal(t, e.Persistent(), a.Persistent())
+		assert.Equal(t, e.Secure(), a.Secure())
+		assert.Equal(t, e.HttpOnly(), a.HttpOnly())
+		assert.Equal(t, e.SameSite(), a.SameSite())
+		assert.Equal(t, e.Creation(), a.Creation())
+		assert.Equal(t, e.LastAccess(), a.LastAccess())
+		if e.Persistent() {
+			assert.Equal(t, e.Expires(), a.Expires())
+		}
+	}
+
+	// session entries in same batch should keep order
+	var names []string
+	for _, i := range actual {
+		if i.Creation().Equal(now) {
+			names = append(names, i.Name())
+		}
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, names)
+}
+
+func mustParseURL(s string) *url.URL {
+	u, err := url.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
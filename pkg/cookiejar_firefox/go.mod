@@ -0,0 +1,31 @@
+module github.com/NateScarlet/cookiejar/pkg/cookiejar_firefox
+
+go 1.23.0
+
+require (
+	github.com/NateScarlet/cookiejar v0.4.0
+	github.com/stretchr/testify v1.8.0
+	golang.org/x/net v0.0.0-20220726230323-06994584191e
+	modernc.org/sqlite v1.38.2
+)
+
+require (
+	github.com/NateScarlet/snapshot v0.6.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
+	golang.org/x/exp v0.0.0-20250620022241-b7579e27df2b // indirect
+	golang.org/x/sys v0.34.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+	modernc.org/libc v1.66.3 // indirect
+	modernc.org/mathutil v1.7.1 // indirect
+	modernc.org/memory v1.11.0 // indirect
+)
+
+// develop against the root module in this repository,
+// consumers use the required release.
+replace github.com/NateScarlet/cookiejar => ../..
@@ -0,0 +1,98 @@
+package cookiejar_firefox
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// originAttributes is the parsed `originAttributes` column,
+// see https://searchfox.org/mozilla-central/source/caps/OriginAttributes.cpp
+type originAttributes struct {
+	userContextID     int
+	privateBrowsingID int
+	partitionKey      string
+	firstPartyDomain  string
+}
+
+func parseOriginAttributes(s string) (ret originAttributes, err error) {
+	if s == "" {
+		return
+	}
+	if !strings.HasPrefix(s, "^") {
+		err = fmt.Errorf("invalid origin attributes '%s'", s)
+		return
+	}
+	values, err := url.ParseQuery(s[1:])
+	if err != nil {
+		return
+	}
+	for k, v := range values {
+		var value = v[len(v)-1]
+		switch k {
+		case "userContextId":
+			ret.userContextID, err = strconv.Atoi(value)
+		case "privateBrowsingId":
+			ret.privateBrowsingID, err = strconv.Atoi(value)
+		case "partitionKey":
+			ret.partitionKey, err = parsePartitionKey(value)
+		case "firstPartyDomain":
+			ret.firstPartyDomain = value
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (o originAttributes) String() string {
+	var b strings.Builder
+	var add = func(k, v string) {
+		if b.Len() == 0 {
+			b.WriteByte('^')
+		} else {
+			b.WriteByte('&')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(url.QueryEscape(v))
+	}
+	// same order as Firefox
+	if o.userContextID != 0 {
+		add("userContextId", strconv.Itoa(o.userContextID))
+	}
+	if o.privateBrowsingID != 0 {
+		add("privateBrowsingId", strconv.Itoa(o.privateBrowsingID))
+	}
+	if o.firstPartyDomain != "" {
+		add("firstPartyDomain", o.firstPartyDomain)
+	}
+	if o.partitionKey != "" {
+		add("partitionKey", formatPartitionKey(o.partitionKey))
+	}
+	return b.String()
+}
+
+// parsePartitionKey converts Firefox "(scheme,baseDomain[,port])" partition
+// key to entry partition key "scheme://baseDomain".
+func parsePartitionKey(s string) (string, error) {
+	if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+		return "", fmt.Errorf("invalid partition key '%s'", s)
+	}
+	var parts = strings.Split(s[1:len(s)-1], ",")
+	if len(parts) < 2 {
+		return "", fmt.Errorf("invalid partition key '%s'", s)
+	}
+	return parts[0] + "://" + parts[1], nil
+}
+
+// formatPartitionKey is the reverse of parsePartitionKey.
+func formatPartitionKey(s string) string {
+	scheme, domain, ok := strings.Cut(s, "://")
+	if !ok {
+		return "(" + s + ")"
+	}
+	return "(" + scheme + "," + domain + ")"
+}
@@ -0,0 +1,41 @@
+package cookiejar_firefox
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOriginAttributes(t *testing.T) {
+	for _, c := range []struct {
+		raw    string
+		expect originAttributes
+	}{
+		{"", originAttributes{}},
+		{"^userContextId=2", originAttributes{userContextID: 2}},
+		{"^privateBrowsingId=1", originAttributes{privateBrowsingID: 1}},
+		{"^partitionKey=%28https%2Cexample.com%29", originAttributes{partitionKey: "https://example.com"}},
+		{"^userContextId=1&partitionKey=%28https%2Cexample.com%29", originAttributes{userContextID: 1, partitionKey: "https://example.com"}},
+	} {
+		t.Run(c.raw, func(t *testing.T) {
+			v, err := parseOriginAttributes(c.raw)
+			require.NoError(t, err)
+			assert.Equal(t, c.expect, v)
+			assert.Equal(t, c.raw, v.String())
+		})
+	}
+
+	t.Run("partition key with port", func(t *testing.T) {
+		v, err := parseOriginAttributes("^partitionKey=%28http%2Clocalhost%2C8080%29")
+		require.NoError(t, err)
+		assert.Equal(t, "http://localhost", v.partitionKey)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := parseOriginAttributes("userContextId=1")
+		assert.Error(t, err)
+		_, err = parseOriginAttributes("^partitionKey=example.com")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,3 @@
+// Package cookiejar_netscape reads and writes entries in Netscape cookies.txt
+// format, which is used by curl, wget and yt-dlp.
+package cookiejar_netscape
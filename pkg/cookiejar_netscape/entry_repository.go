@@ -0,0 +1,273 @@
+package cookiejar_netscape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/NateScarlet/cookiejar/internal/util"
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+)
+
+// ErrReadOnly is returned when modify entries of a repository
+// that not created with OptionWritable.
+var ErrReadOnly = errors.New("cookiejar_netscape: read-only repository")
+
+var errPartitioned = errors.New("partitioned entry is not supported")
+
+type EntryRepository interface {
+	cookiejar.EntryRepository
+	cookiejar.EntryLister
+	cookiejar.EntryToucher
+	Filename() string
+}
+
+type entryRepository struct {
+	filename string
+	opts     *Options
+	mu       sync.Mutex
+	m        map[string]cookiejar.Entry
+	modTime  time.Time
+	size     int64
+}
+
+// load reads file when it changed since last load.
+// caller should hold the lock.
+func (r *entryRepository) load() (err error) {
+	stat, err := os.Stat(r.filename)
+	if errors.Is(err, os.ErrNotExist) {
+		r.m = make(map[string]cookiejar.Entry)
+		r.modTime = time.Time{}
+		r.size = 0
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	if r.m != nil && stat.ModTime().Equal(r.modTime) && stat.Size() == r.size {
+		return
+	}
+	f, err := os.Open(r.filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	var m = make(map[string]cookiejar.Entry)
+	// the file does not record creation time,
+	// entries are at least as old as the file.
+	var modTime = stat.ModTime()
+	err = Read(
+		f,
+		optionsOf(r.opts),
+		OptionClock(cookiejar.ClockFunc(func() time.Time { return modTime })),
+	).ForEach(func(i cookiejar.Entry) (err error) {
+		if old, ok := r.m[i.ID()]; ok {
+			// keep creation and last access of loaded entry.
+			i, err = withMeta(i, old.Creation(), old.LastAccess(), i.Order())
+			if err != nil {
+				return
+			}
+		}
+		m[i.ID()] = i
+		return
+	})
+	if err != nil {
+		return
+	}
+	r.m = m
+	r.modTime = stat.ModTime()
+	r.size = stat.Size()
+	return
+}
+
+// save writes all entries to file.
+// caller should hold the lock.
+func (r *entryRepository) save() (err error) {
+	err = util.AtomicSave(r.filename, func(f *os.File) (err error) {
+		err = f.Chmod(0600)
+		if err != nil {
+			return
+		}
+		return Write(f, cookiejar.EntryIteratorFunc(func(cb func(i cookiejar.Entry) (err error)) (err error) {
+			for _, i := range r.m {
+				err = cb(i)
+				if err != nil {
+					return
+				}
+			}
+			return
+		}))
+	})
+	if err != nil {
+		return
+	}
+	stat, err := os.Stat(r.filename)
+	if err != nil {
+		return
+	}
+	r.modTime = stat.ModTime()
+	r.size = stat.Size()
+	return
+}
+
+func (r *entryRepository) find(filter func(i cookiejar.Entry) bool) cookiejar.EntryIterator {
+	return cookiejar.EntryIteratorFunc(func(cb func(i cookiejar.Entry) (err error)) (err error) {
+		r.mu.Lock()
+		err = r.load()
+		var now = r.opts.clock.Now()
+		var entries []cookiejar.Entry
+		for _, i := range r.m {
+			// expired entries are hidden, since they can not be deleted
+			// from a read-only repository.
+			if i.IsExpiredAt(now) || !filter(i) {
+				continue
+			}
+			entries = append(entries, i)
+		}
+		r.mu.Unlock()
+		if err != nil {
+			return
+		}
+		for _, i := range entries {
+			err = cb(i)
+			if err != nil {
+				return
+			}
+		}
+		return
+	})
+}
+
+// Find implements EntryRepository
+func (r *entryRepository) Find(ctx context.Context, key string) cookiejar.EntryIterator {
+	return r.find(func(i cookiejar.Entry) bool { return i.Key() == key })
+}
+
+// FindAll implements EntryLister
+func (r *entryRepository) FindAll(ctx context.Context) cookiejar.EntryIterator {
+	return r.find(func(i cookiejar.Entry) bool { return true })
+}
+
+// Touch implements EntryToucher,
+// last access time is only kept in memory.
+func (r *entryRepository) Touch(ctx context.Context, id []string, t time.Time) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, i := range id {
+		e, ok := r.m[i]
+		if !ok {
+			continue
+		}
+		r.m[i], err = withMeta(e, e.Creation(), t, e.Order())
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Delete implements EntryRepository
+func (r *entryRepository) Delete(ctx context.Context, id string) (err error) {
+	return r.DeleteMany(ctx, []string{id})
+}
+
+// DeleteMany implements EntryRepository
+func (r *entryRepository) DeleteMany(ctx context.Context, id []string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_netscape: entryRepository.DeleteMany(%s): %w", id, err)
+		}
+	}()
+	if !r.opts.writable {
+		return ErrReadOnly
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err = r.load()
+	if err != nil {
+		return
+	}
+	for _, i := range id {
+		delete(r.m, i)
+	}
+	return r.save()
+}
+
+// Save implements EntryRepository
+func (r *entryRepository) Save(ctx context.Context, entry cookiejar.Entry) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_netscape: entryRepository.Save: %w", err)
+		}
+	}()
+	if !r.opts.writable {
+		return ErrReadOnly
+	}
+	if entry.PartitionKey() != "" {
+		return errPartitioned
+	}
+	err = checkEntry(entry)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err = r.load()
+	if err != nil {
+		return
+	}
+	if old, ok := r.m[entry.ID()]; ok {
+		entry, err = withMeta(entry, old.Creation(), entry.LastAccess(), old.Order())
+		if err != nil {
+			return
+		}
+	}
+	r.m[entry.ID()] = entry
+	return r.save()
+}
+
+// withMeta returns copy of e with creation, last access and order replaced.
+func withMeta(e cookiejar.Entry, creation, lastAccess time.Time, order int) (cookiejar.Entry, error) {
+	ret, err := cookiejar.EntryFromRepository(
+		e.Key(),
+		e.Name(),
+		e.Value(),
+		e.Domain(),
+		e.Path(),
+		e.SameSite(),
+		e.Secure(),
+		e.HttpOnly(),
+		e.Persistent(),
+		e.HostOnly(),
+		e.Expires(),
+		creation,
+		order,
+		cookiejar.EntryOptionPartitionKey(e.PartitionKey()),
+		cookiejar.EntryOptionLastAccess(lastAccess),
+	)
+	if err != nil {
+		return e, err
+	}
+	return *ret, nil
+}
+
+func (r *entryRepository) Filename() string {
+	return r.filename
+}
+
+// NewEntryRepository use a cookies.txt file as repository,
+// file is parsed lazily and reloaded when changed.
+//
+// The repository is read-only unless OptionWritable is used.
+func NewEntryRepository(filename string, options ...Option) EntryRepository {
+	if filename == "" {
+		panic("empty filename")
+	}
+	return &entryRepository{
+		filename: filename,
+		opts:     newOptions(options...),
+	}
+}
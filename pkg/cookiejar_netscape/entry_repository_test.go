@@ -0,0 +1,102 @@
+package cookiejar_netscape
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntryRepository(t *testing.T) {
+	var ctx = context.Background()
+	u, _ := url.Parse("https://www.example.com/path")
+	var useFile = func(t *testing.T, data string) string {
+		var filename = filepath.Join(t.TempDir(), "cookies.txt")
+		require.NoError(t, os.WriteFile(filename, []byte(data), 0600))
+		return filename
+	}
+	var cookieNames = func(jar cookiejar.Jar) (ret []string) {
+		for _, i := range jar.Cookies(u) {
+			ret = append(ret, i.Name)
+		}
+		return
+	}
+
+	t.Run("should read file", func(t *testing.T) {
+		var repo = NewEntryRepository(useFile(t, cookiesTxt))
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"http_only", "host", "domain"}, cookieNames(jar))
+	})
+
+	t.Run("should reload changed file", func(t *testing.T) {
+		var filename = useFile(t, cookiesTxt)
+		var repo = NewEntryRepository(filename)
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo))
+		require.NoError(t, err)
+		assert.Len(t, cookieNames(jar), 3)
+
+		require.NoError(t, os.WriteFile(filename, []byte("www.example.com\tFALSE\t/\tFALSE\t0\tchanged\t1\n"), 0600))
+		var mtime = time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(filename, mtime, mtime))
+		assert.Equal(t, []string{"changed"}, cookieNames(jar))
+	})
+
+	t.Run("should hide expired entries", func(t *testing.T) {
+		var repo = NewEntryRepository(useFile(t, "www.example.com\tFALSE\t/\tFALSE\t1\texpired\t1\n"))
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo))
+		require.NoError(t, err)
+		assert.Len(t, cookieNames(jar), 0)
+	})
+
+	t.Run("should reject write", func(t *testing.T) {
+		var repo = NewEntryRepository(useFile(t, cookiesTxt))
+		var errs []error
+		jar, err := cookiejar.New(
+			ctx,
+			cookiejar.OptionEntryRepository(repo),
+			cookiejar.OptionOnError(func(err error) { errs = append(errs, err) }),
+		)
+		require.NoError(t, err)
+		jar.SetCookies(u, []*http.Cookie{{Name: "a", Value: "1"}})
+		require.Len(t, errs, 1)
+		assert.ErrorIs(t, errs[0], ErrReadOnly)
+		assert.ErrorIs(t, repo.Delete(ctx, "id"), ErrReadOnly)
+	})
+
+	t.Run("should write through", func(t *testing.T) {
+		var filename = useFile(t, cookiesTxt)
+		var repo = NewEntryRepository(filename, OptionWritable())
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo))
+		require.NoError(t, err)
+		jar.SetCookies(u, []*http.Cookie{
+			{Name: "host", MaxAge: -1},
+			{Name: "a", Value: "1", Path: "/"},
+		})
+
+		jar2, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(NewEntryRepository(filename)))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"http_only", "domain", "a"}, cookieNames(jar2))
+	})
+
+	t.Run("should reject unsafe entry", func(t *testing.T) {
+		var filename = useFile(t, cookiesTxt)
+		var repo = NewEntryRepository(filename, OptionWritable())
+		e, err := cookiejar.EntryFromRepository(
+			"example.com", "a", "1\nexample.org\tFALSE\t/\tFALSE\t0\tinjected\t1", "example.com", "/", "", false, false, false, true,
+			time.Time{}, time.Now(), 0,
+		)
+		require.NoError(t, err)
+		assert.ErrorIs(t, repo.Save(ctx, *e), errUnsafeField)
+		data, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		assert.Equal(t, cookiesTxt, string(data))
+	})
+}
@@ -0,0 +1,305 @@
+package cookiejar_netscape
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+	"golang.org/x/net/publicsuffix"
+)
+
+const httpOnlyPrefix = "#HttpOnly_"
+
+const header = "# Netscape HTTP Cookie File\n" +
+	"# https://curl.se/docs/http-cookies.html\n" +
+	"# This file was generated by github.com/NateScarlet/cookiejar. Edit at your own risk.\n\n"
+
+var endOfTime = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+var errMalformedLine = errors.New("malformed line")
+
+var errUnsafeField = errors.New("field contains tab or line break")
+
+// checkEntry rejects entry that would break the line format when written.
+func checkEntry(e cookiejar.Entry) (err error) {
+	for _, v := range []string{e.Domain(), e.Path(), e.Name(), e.Value()} {
+		if strings.ContainsAny(v, "\t\r\n") {
+			return fmt.Errorf("%w: '%s'", errUnsafeField, e.ID())
+		}
+	}
+	return
+}
+
+// Options are the options for reading cookies.txt.
+type Options struct {
+	publicSuffixList cookiejar.PublicSuffixList
+	clock            cookiejar.Clock
+	writable         bool
+}
+
+type Option func(opts *Options)
+
+// OptionPublicSuffixList is used to compute entry key from cookie domain,
+// should be same as the jar that uses the entries.
+//
+// defaults to golang.org/x/net/publicsuffix.List
+func OptionPublicSuffixList(v cookiejar.PublicSuffixList) Option {
+	if v == nil {
+		panic("nil public suffix list")
+	}
+	return func(opts *Options) {
+		opts.publicSuffixList = v
+	}
+}
+
+// OptionClock defines creation time of read entries,
+// defaults to cookiejar.SystemClock().
+func OptionClock(v cookiejar.Clock) Option {
+	if v == nil {
+		panic("nil clock")
+	}
+	return func(opts *Options) {
+		opts.clock = v
+	}
+}
+
+// OptionWritable makes repository created by NewEntryRepository write
+// changes back to the file. Note that the file format can not hold SameSite,
+// creation time and partitioned entries.
+func OptionWritable() Option {
+	return func(opts *Options) {
+		opts.writable = true
+	}
+}
+
+// optionsOf returns option that copies opts.
+func optionsOf(v *Options) Option {
+	return func(opts *Options) {
+		*opts = *v
+	}
+}
+
+func newOptions(options ...Option) *Options {
+	var opts = new(Options)
+	opts.publicSuffixList = publicsuffix.List
+	opts.clock = cookiejar.SystemClock()
+	for _, i := range options {
+		i(opts)
+	}
+	return opts
+}
+
+func parseBool(s string) (bool, error) {
+	switch s {
+	case "TRUE":
+		return true, nil
+	case "FALSE":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean '%s'", s)
+}
+
+func formatBool(v bool) string {
+	if v {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// parseLine parses a cookies.txt line, ok is false for blank or comment line.
+func parseLine(line string, now time.Time, order int, opts *Options) (e *cookiejar.Entry, ok bool, err error) {
+	line = strings.TrimRight(line, "\r\n")
+	var httpOnly bool
+	if strings.HasPrefix(line, httpOnlyPrefix) {
+		httpOnly = true
+		line = line[len(httpOnlyPrefix):]
+	} else if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+		return
+	}
+	var fields = strings.Split(line, "\t")
+	if len(fields) == 6 {
+		// some tools omit empty value
+		fields = append(fields, "")
+	}
+	if len(fields) != 7 {
+		err = errMalformedLine
+		return
+	}
+	var domain = strings.ToLower(fields[0])
+	includeSubdomains, err := parseBool(fields[1])
+	if err != nil {
+		return
+	}
+	var path = fields[2]
+	secure, err := parseBool(fields[3])
+	if err != nil {
+		return
+	}
+	expiry, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return
+	}
+	var name, value = fields[5], fields[6]
+
+	var hostOnly = true
+	if strings.HasPrefix(domain, ".") {
+		domain = domain[1:]
+		hostOnly = false
+	}
+	if includeSubdomains {
+		hostOnly = false
+	}
+	if domain == "" {
+		err = errMalformedLine
+		return
+	}
+	key, err := cookiejar.JarKey(domain, opts.publicSuffixList)
+	if err != nil {
+		return
+	}
+	var persistent = expiry != 0
+	var expires = endOfTime
+	if persistent {
+		expires = time.Unix(expiry, 0).UTC()
+	}
+	e, err = cookiejar.EntryFromRepository(
+		key,
+		name,
+		value,
+		domain,
+		path,
+		"",
+		secure,
+		httpOnly,
+		persistent,
+		hostOnly,
+		expires,
+		now,
+		order,
+	)
+	ok = err == nil
+	return
+}
+
+// Read entries from r in cookies.txt format.
+// Session cookies are represented as expiry 0.
+func Read(r io.Reader, options ...Option) cookiejar.EntryIterator {
+	var opts = newOptions(options...)
+	return cookiejar.EntryIteratorFunc(func(cb func(i cookiejar.Entry) (err error)) (err error) {
+		var now = opts.clock.Now()
+		var br = bufio.NewReader(r)
+		var lineNumber, order int
+		for {
+			lineNumber++
+			line, readErr := br.ReadString('\n')
+			if readErr != nil && readErr != io.EOF {
+				return fmt.Errorf("cookiejar_netscape: Read: %w", readErr)
+			}
+			e, ok, err := parseLine(line, now, order, opts)
+			if err != nil {
+				return fmt.Errorf("cookiejar_netscape: Read: line %d: %w", lineNumber, err)
+			}
+			if ok {
+				err = cb(*e)
+				if err != nil {
+					return err
+				}
+				order++
+			}
+			if readErr == io.EOF {
+				return nil
+			}
+		}
+	})
+}
+
+// Import entries from r in cookies.txt format into repo.
+func Import(ctx context.Context, repo cookiejar.EntryRepository, r io.Reader, options ...Option) (err error) {
+	return Read(r, options...).ForEach(func(i cookiejar.Entry) (err error) {
+		return repo.Save(ctx, i)
+	})
+}
+
+// formatLine formats e as a cookies.txt line without line break.
+func formatLine(e cookiejar.Entry) string {
+	var domain = e.Domain()
+	if !e.HostOnly() {
+		domain = "." + domain
+	}
+	if e.HttpOnly() {
+		domain = httpOnlyPrefix + domain
+	}
+	var expiry int64
+	if e.Persistent() {
+		expiry = e.Expires().Unix()
+	}
+	return strings.Join([]string{
+		domain,
+		formatBool(!e.HostOnly()),
+		e.Path(),
+		formatBool(e.Secure()),
+		strconv.FormatInt(expiry, 10),
+		e.Name(),
+		e.Value(),
+	}, "\t")
+}
+
+// Write entries to w in cookies.txt format, ordered by key and creation.
+// Partitioned entries are skipped since the format can not represent them,
+// entries contains tab or line break are rejected before anything written.
+func Write(w io.Writer, it cookiejar.EntryIterator) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_netscape: Write: %w", err)
+		}
+	}()
+	var entries []cookiejar.Entry
+	err = it.ForEach(func(i cookiejar.Entry) (err error) {
+		if i.PartitionKey() != "" {
+			return
+		}
+		err = checkEntry(i)
+		if err != nil {
+			return
+		}
+		entries = append(entries, i)
+		return
+	})
+	if err != nil {
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		var a, b = entries[i], entries[j]
+		if a.Key() != b.Key() {
+			return a.Key() < b.Key()
+		}
+		if !a.Creation().Equal(b.Creation()) {
+			return a.Creation().Before(b.Creation())
+		}
+		return a.Order() < b.Order()
+	})
+	var bw = bufio.NewWriter(w)
+	_, err = bw.WriteString(header)
+	if err != nil {
+		return
+	}
+	for _, i := range entries {
+		_, err = bw.WriteString(formatLine(i) + "\n")
+		if err != nil {
+			return
+		}
+	}
+	return bw.Flush()
+}
+
+// Export all entries in repo to w in cookies.txt format.
+func Export(ctx context.Context, w io.Writer, repo cookiejar.EntryLister) (err error) {
+	return Write(w, repo.FindAll(ctx))
+}
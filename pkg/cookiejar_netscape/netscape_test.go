@@ -0,0 +1,94 @@
+package cookiejar_netscape
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NateScarlet/cookiejar/internal/test_util"
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const cookiesTxt = header +
+	"www.example.com\tFALSE\t/\tFALSE\t0\thost\t1\n" +
+	".example.com\tTRUE\t/\tTRUE\t4102444800\tdomain\t2\n" +
+	"#HttpOnly_.example.com\tTRUE\t/path\tFALSE\t4102444800\thttp_only\t3\n" +
+	"example.org\tFALSE\t/\tFALSE\t0\tempty\t\n"
+
+func TestNetscape(t *testing.T) {
+	var ctx = context.Background()
+	var clock = test_util.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	t.Run("should read", func(t *testing.T) {
+		var entries []cookiejar.Entry
+		require.NoError(t, Read(strings.NewReader(cookiesTxt), OptionClock(clock)).ForEach(func(i cookiejar.Entry) (err error) {
+			entries = append(entries, i)
+			return
+		}))
+		require.Len(t, entries, 4)
+
+		assert.Equal(t, "example.com", entries[0].Key())
+		assert.Equal(t, "www.example.com", entries[0].Domain())
+		assert.True(t, entries[0].HostOnly())
+		assert.False(t, entries[0].Persistent())
+
+		assert.Equal(t, "example.com", entries[1].Domain())
+		assert.False(t, entries[1].HostOnly())
+		assert.True(t, entries[1].Secure())
+		assert.True(t, entries[1].Persistent())
+		assert.Equal(t, time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC), entries[1].Expires())
+
+		assert.True(t, entries[2].HttpOnly())
+		assert.Equal(t, "/path", entries[2].Path())
+
+		assert.Equal(t, "", entries[3].Value())
+		assert.Equal(t, clock.Now(), entries[3].Creation())
+		assert.Equal(t, 3, entries[3].Order())
+	})
+
+	t.Run("should reject malformed line", func(t *testing.T) {
+		var err = Read(strings.NewReader("# comment\n\nexample.com\tFALSE\t/\n")).ForEach(func(i cookiejar.Entry) (err error) {
+			return
+		})
+		assert.ErrorIs(t, err, errMalformedLine)
+		assert.ErrorContains(t, err, "line 3")
+	})
+
+	t.Run("should import and export", func(t *testing.T) {
+		var repo = cookiejar.NewInMemoryEntryRepository()
+		require.NoError(t, Import(ctx, repo, strings.NewReader(cookiesTxt), OptionClock(clock)))
+
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo), cookiejar.OptionClock(clock))
+		require.NoError(t, err)
+		u, _ := url.Parse("https://www.example.com/path")
+		var names []string
+		for _, i := range jar.Cookies(u) {
+			names = append(names, i.Name)
+		}
+		assert.Equal(t, []string{"http_only", "host", "domain"}, names)
+
+		var b strings.Builder
+		require.NoError(t, Export(ctx, &b, repo.(cookiejar.EntryLister)))
+		assert.Equal(t, cookiesTxt, b.String())
+	})
+
+	t.Run("should reject tab and line break", func(t *testing.T) {
+		for _, value := range []string{"a\tb", "a\nexample.org\tFALSE\t/\tFALSE\t0\tinjected\t1", "a\rb"} {
+			e, err := cookiejar.EntryFromRepository(
+				"example.com", "name", value, "example.com", "/", "", false, false, false, true,
+				time.Time{}, clock.Now(), 0,
+			)
+			require.NoError(t, err)
+			var b strings.Builder
+			err = Write(&b, cookiejar.EntryIteratorFunc(func(cb func(i cookiejar.Entry) (err error)) (err error) {
+				return cb(*e)
+			}))
+			assert.ErrorIs(t, err, errUnsafeField)
+			assert.Empty(t, b.String())
+		}
+	})
+}
@@ -0,0 +1,4 @@
+// Package cookiejar_playwright reads and writes entries in Playwright
+// `storageState` JSON format, cookie arrays returned by Puppeteer
+// `page.cookies()` are also accepted when reading.
+package cookiejar_playwright
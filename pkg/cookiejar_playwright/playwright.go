@@ -0,0 +1,284 @@
+package cookiejar_playwright
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+	"golang.org/x/net/publicsuffix"
+)
+
+var endOfTime = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+// cookie in storage state, see https://playwright.dev/docs/api/class-browsercontext#browser-context-storage-state
+type cookie struct {
+	Name     string  `json:"name"`
+	Value    string  `json:"value"`
+	Domain   string  `json:"domain"`
+	Path     string  `json:"path"`
+	Expires  float64 `json:"expires"`
+	HTTPOnly bool    `json:"httpOnly"`
+	Secure   bool    `json:"secure"`
+	SameSite string  `json:"sameSite,omitempty"`
+	// string for Playwright, object for Puppeteer.
+	PartitionKey json.RawMessage `json:"partitionKey,omitempty"`
+}
+
+type storageState struct {
+	Cookies []cookie          `json:"cookies"`
+	Origins []json.RawMessage `json:"origins"`
+}
+
+// Options are the options for reading storage state.
+type Options struct {
+	publicSuffixList cookiejar.PublicSuffixList
+	clock            cookiejar.Clock
+}
+
+type Option func(opts *Options)
+
+// OptionPublicSuffixList is used to compute entry key from cookie domain,
+// should be same as the jar that uses the entries.
+//
+// defaults to golang.org/x/net/publicsuffix.List
+func OptionPublicSuffixList(v cookiejar.PublicSuffixList) Option {
+	if v == nil {
+		panic("nil public suffix list")
+	}
+	return func(opts *Options) {
+		opts.publicSuffixList = v
+	}
+}
+
+// OptionClock defines creation time of read entries,
+// defaults to cookiejar.SystemClock().
+func OptionClock(v cookiejar.Clock) Option {
+	if v == nil {
+		panic("nil clock")
+	}
+	return func(opts *Options) {
+		opts.clock = v
+	}
+}
+
+func newOptions(options ...Option) *Options {
+	var opts = new(Options)
+	opts.publicSuffixList = publicsuffix.List
+	opts.clock = cookiejar.SystemClock()
+	for _, i := range options {
+		i(opts)
+	}
+	return opts
+}
+
+func parseSameSite(v string) string {
+	switch v {
+	case "Strict", "Lax", "None":
+		return "SameSite=" + v
+	}
+	return ""
+}
+
+func formatSameSite(v string) string {
+	switch v {
+	case "SameSite=Strict":
+		return "Strict"
+	case "SameSite=Lax":
+		return "Lax"
+	case "SameSite=None":
+		return "None"
+	}
+	return ""
+}
+
+func parsePartitionKey(v json.RawMessage) (ret string, err error) {
+	if len(v) == 0 || string(v) == "null" {
+		return
+	}
+	if v[0] == '"' {
+		err = json.Unmarshal(v, &ret)
+		return
+	}
+	var obj struct {
+		SourceOrigin string `json:"sourceOrigin"`
+	}
+	err = json.Unmarshal(v, &obj)
+	ret = obj.SourceOrigin
+	return
+}
+
+func parseExpires(v float64) time.Time {
+	sec, frac := math.Modf(v)
+	return time.Unix(int64(sec), int64(math.Round(frac*1e6))*1e3).UTC()
+}
+
+func formatExpires(v time.Time) float64 {
+	return float64(v.UnixMicro()) / 1e6
+}
+
+// parseCookie converts c to entry, domain with leading dot is a domain cookie,
+// otherwise host-only. Negative expires is a session cookie.
+func parseCookie(c cookie, now time.Time, order int, opts *Options) (e *cookiejar.Entry, err error) {
+	var domain = strings.ToLower(c.Domain)
+	var hostOnly = true
+	if strings.HasPrefix(domain, ".") {
+		domain = domain[1:]
+		hostOnly = false
+	}
+	if domain == "" {
+		err = fmt.Errorf("cookie '%s': empty domain", c.Name)
+		return
+	}
+	key, err := cookiejar.JarKey(domain, opts.publicSuffixList)
+	if err != nil {
+		return
+	}
+	partitionKey, err := parsePartitionKey(c.PartitionKey)
+	if err != nil {
+		return
+	}
+	var path = c.Path
+	if path == "" {
+		path = "/"
+	}
+	var persistent = c.Expires >= 0
+	var expires = endOfTime
+	if persistent {
+		expires = parseExpires(c.Expires)
+	}
+	return cookiejar.EntryFromRepository(
+		key,
+		c.Name,
+		c.Value,
+		domain,
+		path,
+		parseSameSite(c.SameSite),
+		c.Secure,
+		c.HTTPOnly,
+		persistent,
+		hostOnly,
+		expires,
+		now,
+		order,
+		cookiejar.EntryOptionPartitionKey(partitionKey),
+	)
+}
+
+func formatCookie(e cookiejar.Entry) (ret cookie) {
+	ret.Name = e.Name()
+	ret.Value = e.Value()
+	ret.Domain = e.Domain()
+	if !e.HostOnly() {
+		ret.Domain = "." + ret.Domain
+	}
+	ret.Path = e.Path()
+	ret.Expires = -1
+	if e.Persistent() {
+		ret.Expires = formatExpires(e.Expires())
+	}
+	ret.HTTPOnly = e.HttpOnly()
+	ret.Secure = e.Secure()
+	ret.SameSite = formatSameSite(e.SameSite())
+	if e.PartitionKey() != "" {
+		ret.PartitionKey, _ = json.Marshal(e.PartitionKey())
+	}
+	return
+}
+
+// Read entries from r in storage state format, a bare cookie array
+// (Puppeteer `page.cookies()` output) is also accepted.
+// Cookies are ordered as they appear in r.
+func Read(r io.Reader, options ...Option) cookiejar.EntryIterator {
+	var opts = newOptions(options...)
+	return cookiejar.EntryIteratorFunc(func(cb func(i cookiejar.Entry) (err error)) (err error) {
+		defer func() {
+			if err != nil {
+				err = fmt.Errorf("cookiejar_playwright: Read: %w", err)
+			}
+		}()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return
+		}
+		var cookies []cookie
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+			err = json.Unmarshal(data, &cookies)
+		} else {
+			var state storageState
+			err = json.Unmarshal(data, &state)
+			cookies = state.Cookies
+		}
+		if err != nil {
+			return
+		}
+		var now = opts.clock.Now()
+		for order, c := range cookies {
+			e, err := parseCookie(c, now, order, opts)
+			if err != nil {
+				return err
+			}
+			err = cb(*e)
+			if err != nil {
+				return err
+			}
+		}
+		return
+	})
+}
+
+// Import entries from r in storage state format into repo.
+func Import(ctx context.Context, repo cookiejar.EntryRepository, r io.Reader, options ...Option) (err error) {
+	return Read(r, options...).ForEach(func(i cookiejar.Entry) (err error) {
+		return repo.Save(ctx, i)
+	})
+}
+
+// Write entries to w in storage state format with empty origins,
+// ordered by key and creation. Session cookies are written with expires -1.
+func Write(w io.Writer, it cookiejar.EntryIterator) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_playwright: Write: %w", err)
+		}
+	}()
+	var entries []cookiejar.Entry
+	err = it.ForEach(func(i cookiejar.Entry) (err error) {
+		entries = append(entries, i)
+		return
+	})
+	if err != nil {
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		var a, b = entries[i], entries[j]
+		if a.Key() != b.Key() {
+			return a.Key() < b.Key()
+		}
+		if !a.Creation().Equal(b.Creation()) {
+			return a.Creation().Before(b.Creation())
+		}
+		return a.Order() < b.Order()
+	})
+	var state = storageState{
+		Cookies: make([]cookie, 0, len(entries)),
+		Origins: []json.RawMessage{},
+	}
+	for _, i := range entries {
+		state.Cookies = append(state.Cookies, formatCookie(i))
+	}
+	var enc = json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(state)
+}
+
+// Export all entries in repo to w in storage state format.
+func Export(ctx context.Context, w io.Writer, repo cookiejar.EntryLister) (err error) {
+	return Write(w, repo.FindAll(ctx))
+}
@@ -0,0 +1,137 @@
+package cookiejar_playwright
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NateScarlet/cookiejar/internal/test_util"
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const storageStateJSON = `{
+  "cookies": [
+    {
+      "name": "host",
+      "value": "1",
+      "domain": "www.example.com",
+      "path": "/",
+      "expires": -1,
+      "httpOnly": false,
+      "secure": false,
+      "sameSite": "Lax"
+    },
+    {
+      "name": "domain",
+      "value": "2",
+      "domain": ".example.com",
+      "path": "/",
+      "expires": 4102444800.5,
+      "httpOnly": false,
+      "secure": true,
+      "sameSite": "Strict"
+    },
+    {
+      "name": "http_only",
+      "value": "3",
+      "domain": ".example.com",
+      "path": "/path",
+      "expires": 4102444800,
+      "httpOnly": true,
+      "secure": false
+    },
+    {
+      "name": "partitioned",
+      "value": "4",
+      "domain": "widget.example.net",
+      "path": "/",
+      "expires": -1,
+      "httpOnly": false,
+      "secure": true,
+      "sameSite": "None",
+      "partitionKey": "https://example.org"
+    }
+  ],
+  "origins": []
+}
+`
+
+func collect(t *testing.T, it cookiejar.EntryIterator) (ret []cookiejar.Entry) {
+	require.NoError(t, it.ForEach(func(i cookiejar.Entry) (err error) {
+		ret = append(ret, i)
+		return
+	}))
+	return
+}
+
+func TestPlaywright(t *testing.T) {
+	var ctx = context.Background()
+	var clock = test_util.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	t.Run("should read", func(t *testing.T) {
+		var entries = collect(t, Read(strings.NewReader(storageStateJSON), OptionClock(clock)))
+		require.Len(t, entries, 4)
+
+		assert.Equal(t, "example.com", entries[0].Key())
+		assert.Equal(t, "www.example.com", entries[0].Domain())
+		assert.True(t, entries[0].HostOnly())
+		assert.False(t, entries[0].Persistent())
+		assert.Equal(t, "SameSite=Lax", entries[0].SameSite())
+
+		assert.Equal(t, "example.com", entries[1].Domain())
+		assert.False(t, entries[1].HostOnly())
+		assert.True(t, entries[1].Secure())
+		assert.True(t, entries[1].Persistent())
+		assert.Equal(t, time.Date(2100, 1, 1, 0, 0, 0, 5e8, time.UTC), entries[1].Expires())
+		assert.Equal(t, "SameSite=Strict", entries[1].SameSite())
+
+		assert.True(t, entries[2].HttpOnly())
+		assert.Equal(t, "/path", entries[2].Path())
+		assert.Equal(t, "", entries[2].SameSite())
+
+		assert.Equal(t, "example.net", entries[3].Key())
+		assert.Equal(t, "https://example.org", entries[3].PartitionKey())
+		assert.Equal(t, clock.Now(), entries[3].Creation())
+		assert.Equal(t, 3, entries[3].Order())
+	})
+
+	t.Run("should read puppeteer cookies", func(t *testing.T) {
+		var entries = collect(t, Read(strings.NewReader(`[
+  {"name": "a", "value": "1", "domain": "example.com", "path": "/", "expires": -1, "size": 2, "httpOnly": false, "secure": false, "session": true},
+  {"name": "b", "value": "2", "domain": "example.net", "path": "/", "expires": -1, "size": 2, "httpOnly": false, "secure": true, "session": true, "partitionKey": {"sourceOrigin": "https://example.org", "hasCrossSiteAncestor": false}}
+]`), OptionClock(clock)))
+		require.Len(t, entries, 2)
+		assert.Equal(t, "a", entries[0].Name())
+		assert.False(t, entries[0].Persistent())
+		assert.Equal(t, "https://example.org", entries[1].PartitionKey())
+	})
+
+	t.Run("should reject empty domain", func(t *testing.T) {
+		var err = Read(strings.NewReader(`{"cookies": [{"name": "a", "value": "1"}]}`)).ForEach(func(i cookiejar.Entry) (err error) {
+			return
+		})
+		assert.ErrorContains(t, err, "empty domain")
+	})
+
+	t.Run("should import and export", func(t *testing.T) {
+		var repo = cookiejar.NewInMemoryEntryRepository()
+		require.NoError(t, Import(ctx, repo, strings.NewReader(storageStateJSON), OptionClock(clock)))
+
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo), cookiejar.OptionClock(clock))
+		require.NoError(t, err)
+		u, _ := url.Parse("https://www.example.com/path")
+		var names []string
+		for _, i := range jar.Cookies(u) {
+			names = append(names, i.Name)
+		}
+		assert.Equal(t, []string{"http_only", "host", "domain"}, names)
+
+		var b strings.Builder
+		require.NoError(t, Export(ctx, &b, repo.(cookiejar.EntryLister)))
+		assert.Equal(t, storageStateJSON, b.String())
+	})
+}
@@ -0,0 +1,81 @@
+// Package cookiejar_record is the JSON encoding of entries for key-value
+// repository implementations, jar key and id are stored outside the record.
+//
+// It is used by `cookiejar_bolt` and `cookiejar_redis`, the encoding is kept
+// compatible so stored records stay readable after upgrade.
+package cookiejar_record
+
+import (
+	"time"
+
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+)
+
+var endOfTime = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+// Record is the stored value of an entry.
+type Record struct {
+	Name         string     `json:"name,omitempty"`
+	Value        string     `json:"value,omitempty"`
+	Domain       string     `json:"domain,omitempty"`
+	Path         string     `json:"path,omitempty"`
+	SameSite     string     `json:"sameSite,omitempty"`
+	Secure       bool       `json:"secure,omitempty"`
+	HttpOnly     bool       `json:"httpOnly,omitempty"`
+	Persistent   bool       `json:"persistent,omitempty"`
+	HostOnly     bool       `json:"hostOnly,omitempty"`
+	PartitionKey string     `json:"partitionKey,omitempty"`
+	Expires      *time.Time `json:"expires,omitempty"`
+	Creation     time.Time  `json:"creation"`
+	LastAccess   time.Time  `json:"lastAccess"`
+	Order        int        `json:"order,omitempty"`
+}
+
+// New creates record from entry.
+func New(do cookiejar.Entry) *Record {
+	var ret = &Record{
+		Name:         do.Name(),
+		Value:        do.Value(),
+		Domain:       do.Domain(),
+		Path:         do.Path(),
+		SameSite:     do.SameSite(),
+		Secure:       do.Secure(),
+		HttpOnly:     do.HttpOnly(),
+		Persistent:   do.Persistent(),
+		HostOnly:     do.HostOnly(),
+		PartitionKey: do.PartitionKey(),
+		Creation:     do.Creation().UTC(),
+		LastAccess:   do.LastAccess().UTC(),
+		Order:        do.Order(),
+	}
+	if v := do.Expires(); !v.IsZero() && !v.Equal(endOfTime) {
+		v = v.UTC()
+		ret.Expires = &v
+	}
+	return ret
+}
+
+// DomainObject restores entry stored under jar key.
+func (obj Record) DomainObject(key string) (_ *cookiejar.Entry, err error) {
+	var expires = endOfTime
+	if obj.Expires != nil {
+		expires = *obj.Expires
+	}
+	return cookiejar.EntryFromRepository(
+		key,
+		obj.Name,
+		obj.Value,
+		obj.Domain,
+		obj.Path,
+		obj.SameSite,
+		obj.Secure,
+		obj.HttpOnly,
+		obj.Persistent,
+		obj.HostOnly,
+		expires,
+		obj.Creation,
+		obj.Order,
+		cookiejar.EntryOptionPartitionKey(obj.PartitionKey),
+		cookiejar.EntryOptionLastAccess(obj.LastAccess),
+	)
+}
@@ -0,0 +1,47 @@
+package cookiejar_record
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/NateScarlet/cookiejar/internal/test_util"
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord(t *testing.T) {
+	var ctx = context.Background()
+	var clock = test_util.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	var repo = cookiejar.NewInMemoryEntryRepository()
+	jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo), cookiejar.OptionClock(clock))
+	require.NoError(t, err)
+	u, _ := url.Parse("https://www.example.com")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "1", HttpOnly: true, SameSite: http.SameSiteStrictMode},
+		{Name: "persistent", Value: "2", Domain: "example.com", MaxAge: 3600, Secure: true},
+	})
+	jar.SetCookiesForRequest(u, cookiejar.RequestContext{
+		TopLevelSite: &url.URL{Scheme: "https", Host: "example.org"},
+	}, []*http.Cookie{
+		{Name: "partitioned", Value: "3", Secure: true, Partitioned: true},
+	})
+
+	var count int
+	require.NoError(t, repo.(cookiejar.EntryLister).FindAll(ctx).ForEach(func(e cookiejar.Entry) (err error) {
+		count++
+		data, err := json.Marshal(New(e))
+		require.NoError(t, err)
+		var r Record
+		require.NoError(t, json.Unmarshal(data, &r))
+		got, err := r.DomainObject(e.Key())
+		require.NoError(t, err)
+		assert.Equal(t, e, *got, e.Name())
+		return
+	}))
+	assert.Equal(t, 3, count)
+}
@@ -0,0 +1,9 @@
+// Package cookiejar_redis stores entries in Redis, so multiple services can
+// share one cookie session.
+//
+// Entries of each jar key are stored as a hash, persistent entries use hash
+// field expiration that requires Redis 7.4 or later. An id index hash maps
+// entry id to jar key, it is used to delete and list entries without
+// scanning keys. Redis Cluster is supported when key prefix contains a hash
+// tag, see OptionKeyPrefix.
+package cookiejar_redis
@@ -0,0 +1,320 @@
+package cookiejar_redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar_record"
+	"github.com/redis/go-redis/v9"
+)
+
+// saveScript saves entry and keeps creation and order of existing entry.
+//
+// KEYS: entries hash, id index hash
+// ARGV: id, entry json, jar key, ttl seconds (0 for no ttl)
+var saveScript = redis.NewScript(`
+local value = ARGV[2]
+local old = redis.call('HGET', KEYS[1], ARGV[1])
+if old then
+	local o = cjson.decode(old)
+	local n = cjson.decode(value)
+	n.creation = o.creation
+	n.order = o.order
+	value = cjson.encode(n)
+end
+redis.call('HSET', KEYS[1], ARGV[1], value)
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[3])
+local ttl = tonumber(ARGV[4])
+for _, k in ipairs(KEYS) do
+	if ttl > 0 then
+		redis.call('HEXPIRE', k, ttl, 'FIELDS', 1, ARGV[1])
+	else
+		redis.call('HPERSIST', k, 'FIELDS', 1, ARGV[1])
+	end
+end
+return 1
+`)
+
+// touchScript updates last access of entry and keeps its ttl.
+//
+// KEYS: entries hash
+// ARGV: id, last access
+var touchScript = redis.NewScript(`
+local old = redis.call('HGET', KEYS[1], ARGV[1])
+if not old then
+	return 0
+end
+local ttl = redis.call('HTTL', KEYS[1], 'FIELDS', 1, ARGV[1])[1]
+local o = cjson.decode(old)
+o.lastAccess = ARGV[2]
+redis.call('HSET', KEYS[1], ARGV[1], cjson.encode(o))
+if ttl > 0 then
+	redis.call('HEXPIRE', KEYS[1], ttl, 'FIELDS', 1, ARGV[1])
+end
+return 1
+`)
+
+type EntryRepository interface {
+	cookiejar.EntryRepository
+	cookiejar.EntryLister
+	cookiejar.EntryToucher
+}
+
+type entryRepository struct {
+	client redis.UniversalClient
+	prefix string
+	clock  cookiejar.Clock
+}
+
+// entriesKey is the hash key that stores entries of jar key.
+func (r *entryRepository) entriesKey(key string) string {
+	return r.prefix + "entries:" + key
+}
+
+// idsKey is the hash key that maps entry id to jar key.
+func (r *entryRepository) idsKey() string {
+	return r.prefix + "ids"
+}
+
+func decodeEntries(key string, m map[string]string) (ret []cookiejar.Entry, err error) {
+	ret = make([]cookiejar.Entry, 0, len(m))
+	for _, v := range m {
+		var po cookiejar_record.Record
+		err = json.Unmarshal([]byte(v), &po)
+		if err != nil {
+			return
+		}
+		do, err := po.DomainObject(key)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, *do)
+	}
+	return
+}
+
+func (r *entryRepository) find(ctx context.Context, key string, cb func(i cookiejar.Entry) (err error)) (err error) {
+	m, err := r.client.HGetAll(ctx, r.entriesKey(key)).Result()
+	if err != nil {
+		return
+	}
+	entries, err := decodeEntries(key, m)
+	if err != nil {
+		return
+	}
+	for _, i := range entries {
+		err = cb(i)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Find implements EntryRepository
+func (r *entryRepository) Find(ctx context.Context, key string) cookiejar.EntryIterator {
+	return cookiejar.EntryIteratorFunc(func(cb func(i cookiejar.Entry) (err error)) (err error) {
+		defer func() {
+			if err != nil {
+				err = fmt.Errorf("cookiejar_redis: entryRepository.Find('%s'): %w", key, err)
+			}
+		}()
+		return r.find(ctx, key, cb)
+	})
+}
+
+// FindAll implements EntryLister,
+// it lists jar keys from the id index hash instead of scanning keys,
+// so it also works on Redis Cluster when prefix contains a hash tag.
+func (r *entryRepository) FindAll(ctx context.Context) cookiejar.EntryIterator {
+	return cookiejar.EntryIteratorFunc(func(cb func(i cookiejar.Entry) (err error)) (err error) {
+		defer func() {
+			if err != nil {
+				err = fmt.Errorf("cookiejar_redis: entryRepository.FindAll: %w", err)
+			}
+		}()
+		values, err := r.client.HVals(ctx, r.idsKey()).Result()
+		if err != nil {
+			return
+		}
+		var keys []string
+		var seen = make(map[string]struct{}, len(values))
+		for _, key := range values {
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			keys = append(keys, key)
+		}
+		for _, key := range keys {
+			err = r.find(ctx, key, cb)
+			if err != nil {
+				return
+			}
+		}
+		return
+	})
+}
+
+// keysOf returns jar key of each id, empty string for missing id.
+func (r *entryRepository) keysOf(ctx context.Context, id []string) (ret []string, err error) {
+	values, err := r.client.HMGet(ctx, r.idsKey(), id...).Result()
+	if err != nil {
+		return
+	}
+	ret = make([]string, len(values))
+	for index, v := range values {
+		if s, ok := v.(string); ok {
+			ret[index] = s
+		}
+	}
+	return
+}
+
+// Delete implements EntryRepository
+func (r *entryRepository) Delete(ctx context.Context, id string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_redis: entryRepository.Delete('%s'): %w", id, err)
+		}
+	}()
+	return r.DeleteMany(ctx, []string{id})
+}
+
+// DeleteMany implements EntryRepository
+func (r *entryRepository) DeleteMany(ctx context.Context, id []string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_redis: entryRepository.DeleteMany(%s): %w", id, err)
+		}
+	}()
+	if len(id) == 0 {
+		return
+	}
+	keys, err := r.keysOf(ctx, id)
+	if err != nil {
+		return
+	}
+	_, err = r.client.TxPipelined(ctx, func(p redis.Pipeliner) (err error) {
+		for index, i := range id {
+			if keys[index] != "" {
+				p.HDel(ctx, r.entriesKey(keys[index]), i)
+			}
+		}
+		p.HDel(ctx, r.idsKey(), id...)
+		return
+	})
+	return
+}
+
+// Touch implements EntryToucher
+func (r *entryRepository) Touch(ctx context.Context, id []string, t time.Time) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_redis: entryRepository.Touch(%s): %w", id, err)
+		}
+	}()
+	if len(id) == 0 {
+		return
+	}
+	keys, err := r.keysOf(ctx, id)
+	if err != nil {
+		return
+	}
+	var lastAccess = t.UTC().Format(time.RFC3339Nano)
+	for index, i := range id {
+		if keys[index] == "" {
+			continue
+		}
+		err = touchScript.Run(ctx, r.client, []string{r.entriesKey(keys[index])}, i, lastAccess).Err()
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Save implements EntryRepository,
+// persistent entry expires with hash field ttl.
+func (r *entryRepository) Save(ctx context.Context, e cookiejar.Entry) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_redis: entryRepository.Save: %w", err)
+		}
+	}()
+	data, err := json.Marshal(cookiejar_record.New(e))
+	if err != nil {
+		return
+	}
+	var ttl int64
+	if e.Persistent() {
+		ttl = int64(math.Ceil(e.Expires().Sub(r.clock.Now()).Seconds()))
+		if ttl < 1 {
+			ttl = 1
+		}
+	}
+	return saveScript.Run(
+		ctx,
+		r.client,
+		[]string{r.entriesKey(e.Key()), r.idsKey()},
+		e.ID(),
+		string(data),
+		e.Key(),
+		ttl,
+	).Err()
+}
+
+// Options are the options for creating a new EntryRepository.
+type Options struct {
+	prefix string
+	clock  cookiejar.Clock
+}
+
+type Option func(opts *Options)
+
+// OptionKeyPrefix defines prefix of redis keys used by the repository,
+// defaults to `cookiejar:`. Use a hash tag (e.g. `{cookiejar}:`) for
+// Redis Cluster, so all keys of the repository are in one slot.
+func OptionKeyPrefix(v string) Option {
+	return func(opts *Options) {
+		opts.prefix = v
+	}
+}
+
+// OptionClock defines time source to compute ttl from entry expires,
+// defaults to cookiejar.SystemClock().
+func OptionClock(v cookiejar.Clock) Option {
+	if v == nil {
+		panic("nil clock")
+	}
+	return func(opts *Options) {
+		opts.clock = v
+	}
+}
+
+func newOptions(options ...Option) *Options {
+	var opts = new(Options)
+	opts.prefix = "cookiejar:"
+	opts.clock = cookiejar.SystemClock()
+	for _, i := range options {
+		i(opts)
+	}
+	return opts
+}
+
+// NewEntryRepository use client to store cookies.
+func NewEntryRepository(client redis.UniversalClient, options ...Option) EntryRepository {
+	if client == nil {
+		panic("nil client")
+	}
+	var opts = newOptions(options...)
+	return &entryRepository{
+		client: client,
+		prefix: opts.prefix,
+		clock:  opts.clock,
+	}
+}
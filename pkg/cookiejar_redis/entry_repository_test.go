@@ -0,0 +1,175 @@
+package cookiejar_redis
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/NateScarlet/cookiejar/internal/test_util"
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntryRepository(t *testing.T) {
+	var ctx = context.Background()
+	url1, _ := url.Parse("http://example.com")
+	var useServer = func(t *testing.T) (*miniredis.Miniredis, redis.UniversalClient) {
+		var s = miniredis.RunT(t)
+		var client = redis.NewClient(&redis.Options{Addr: s.Addr()})
+		t.Cleanup(func() { client.Close() })
+		return s, client
+	}
+	var useJar = func(t *testing.T, client redis.UniversalClient) (cookiejar.Jar, EntryRepository, *test_util.Clock) {
+		var clock = test_util.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+		var repo = NewEntryRepository(client, OptionClock(clock))
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo), cookiejar.OptionClock(clock))
+		require.NoError(t, err)
+		return jar, repo, clock
+	}
+
+	t.Run("should isolate key prefix", func(t *testing.T) {
+		var s, client = useServer(t)
+		var jar1, _, _ = useJar(t, client)
+		jar2, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(
+			NewEntryRepository(client, OptionKeyPrefix("other:")),
+		))
+		require.NoError(t, err)
+		jar1.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		assert.Len(t, jar1.Cookies(url1), 1)
+		assert.Len(t, jar2.Cookies(url1), 0)
+		assert.True(t, s.Exists("cookiejar:entries:example.com"))
+		assert.False(t, s.Exists("other:entries:example.com"))
+		assert.Equal(t, "example.com", s.HGet("cookiejar:ids", "example.com;example.com;/;a"))
+	})
+
+	t.Run("should delete many across keys", func(t *testing.T) {
+		var s, client = useServer(t)
+		var jar, repo, _ = useJar(t, client)
+		url2, _ := url.Parse("http://example.org")
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}})
+		jar.SetCookies(url2, []*http.Cookie{{Name: "c", Value: "3"}})
+		require.NoError(t, repo.DeleteMany(ctx, []string{
+			"example.com;example.com;/;a",
+			"example.org;example.org;/;c",
+			"example.org;example.org;/;missing",
+		}))
+		assert.Len(t, jar.Cookies(url1), 1)
+		assert.Len(t, jar.Cookies(url2), 0)
+		assert.False(t, s.Exists("cookiejar:entries:example.org"))
+		ids, err := s.HKeys("cookiejar:ids")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"example.com;example.com;/;b"}, ids)
+	})
+
+	t.Run("should find all from id index", func(t *testing.T) {
+		var s, client = useServer(t)
+		var clock = test_util.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+		var repo = NewEntryRepository(client, OptionClock(clock), OptionKeyPrefix("{cookiejar}:"))
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo), cookiejar.OptionClock(clock))
+		require.NoError(t, err)
+		url2, _ := url.Parse("http://example.org")
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}})
+		jar.SetCookies(url2, []*http.Cookie{{Name: "c", Value: "3"}})
+		// not indexed, should be ignored
+		s.HSet("{cookiejar}:entries:example.net", "example.net;example.net;/;d", `{"name":"d"}`)
+		var names []string
+		require.NoError(t, repo.FindAll(ctx).ForEach(func(i cookiejar.Entry) (err error) {
+			names = append(names, i.Name())
+			return
+		}))
+		assert.ElementsMatch(t, []string{"a", "b", "c"}, names)
+	})
+
+	t.Run("should expire with ttl", func(t *testing.T) {
+		var s, client = useServer(t)
+		var jar, repo, clock = useJar(t, client)
+		jar.SetCookies(url1, []*http.Cookie{
+			{Name: "a", Value: "1", MaxAge: 60},
+			{Name: "b", Value: "2"},
+		})
+		assert.Equal(t, 60*time.Second, s.HTTL("cookiejar:entries:example.com", "example.com;example.com;/;a"))
+		clock.Add(time.Second)
+		assert.Len(t, jar.Cookies(url1), 2)
+		s.FastForward(time.Minute)
+		var names []string
+		require.NoError(t, repo.Find(ctx, "example.com").ForEach(func(i cookiejar.Entry) (err error) {
+			names = append(names, i.Name())
+			return
+		}))
+		assert.Equal(t, []string{"b"}, names)
+		ids, err := s.HKeys("cookiejar:ids")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"example.com;example.com;/;b"}, ids)
+	})
+
+	t.Run("should able to delete", func(t *testing.T) {
+		var s, client = useServer(t)
+		var jar, _, _ = useJar(t, client)
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", MaxAge: -1}})
+		assert.Len(t, jar.Cookies(url1), 0)
+		assert.False(t, s.Exists("cookiejar:entries:example.com"))
+		assert.False(t, s.Exists("cookiejar:ids"))
+	})
+
+	t.Run("should keep creation and order", func(t *testing.T) {
+		var s, client = useServer(t)
+		var jar, repo, clock = useJar(t, client)
+		jar.SetCookies(url1, []*http.Cookie{
+			{Name: "a", Value: "1"},
+			{Name: "b", Value: "2", MaxAge: 60},
+		})
+		var created = clock.Now()
+		clock.Add(time.Minute)
+		jar.SetCookies(url1, []*http.Cookie{
+			{Name: "b", Value: "3"},
+		})
+		var entries = make(map[string]cookiejar.Entry)
+		require.NoError(t, repo.Find(ctx, "example.com").ForEach(func(i cookiejar.Entry) (err error) {
+			entries[i.Name()] = i
+			return
+		}))
+		require.Len(t, entries, 2)
+		assert.Equal(t, "3", entries["b"].Value())
+		assert.Equal(t, created, entries["b"].Creation())
+		assert.Equal(t, 1, entries["b"].Order())
+		assert.False(t, entries["b"].Persistent())
+		// session entry should not expire
+		s.FastForward(time.Hour)
+		assert.Len(t, jar.Cookies(url1), 2)
+	})
+
+	t.Run("should touch and keep ttl", func(t *testing.T) {
+		var s, client = useServer(t)
+		var jar, repo, clock = useJar(t, client)
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1", MaxAge: 3600}})
+		s.FastForward(time.Minute)
+		clock.Add(time.Minute)
+		jar.Cookies(url1)
+		require.NoError(t, repo.Find(ctx, "example.com").ForEach(func(i cookiejar.Entry) (err error) {
+			assert.Equal(t, clock.Now(), i.LastAccess())
+			return
+		}))
+		assert.Equal(t, 59*time.Minute, s.HTTL("cookiejar:entries:example.com", "example.com;example.com;/;a"))
+	})
+
+	t.Run("should share between repositories", func(t *testing.T) {
+		var _, client = useServer(t)
+		var jar1, _, _ = useJar(t, client)
+		var jar2, _, _ = useJar(t, client)
+		url2, _ := url.Parse("http://example.org")
+		jar1.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		jar1.SetCookies(url2, []*http.Cookie{{Name: "b", Value: "2"}})
+		assert.Len(t, jar2.Cookies(url1), 1)
+		all, err := jar2.All()
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+		require.NoError(t, jar2.Clear())
+		assert.Len(t, jar1.Cookies(url1), 0)
+	})
+}
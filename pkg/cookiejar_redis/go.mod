@@ -0,0 +1,25 @@
+module github.com/NateScarlet/cookiejar/pkg/cookiejar_redis
+
+go 1.23.0
+
+require (
+	github.com/NateScarlet/cookiejar v0.4.0
+	github.com/alicebob/miniredis/v2 v2.39.0
+	github.com/redis/go-redis/v9 v9.17.0
+	github.com/stretchr/testify v1.8.0
+)
+
+require (
+	github.com/NateScarlet/snapshot v0.6.0 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+	golang.org/x/net v0.0.0-20220726230323-06994584191e // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// develop against the root module in this repository,
+// consumers use the required release.
+replace github.com/NateScarlet/cookiejar => ../..
@@ -0,0 +1,42 @@
+package cookiejar_sql
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect defines SQL syntax differences between databases.
+type Dialect int
+
+const (
+	DialectSQLite Dialect = iota
+	DialectPostgreSQL
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case DialectSQLite:
+		return "SQLite"
+	case DialectPostgreSQL:
+		return "PostgreSQL"
+	}
+	return "Dialect(" + strconv.Itoa(int(d)) + ")"
+}
+
+// placeholder returns n-th (1-based) bind parameter.
+func (d Dialect) placeholder(n int) string {
+	if d == DialectPostgreSQL {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// placeholders returns bind parameters from n-th (1-based) to (n+count-1)-th,
+// separated by comma.
+func (d Dialect) placeholders(n, count int) string {
+	var parts = make([]string, count)
+	for i := range parts {
+		parts[i] = d.placeholder(n + i)
+	}
+	return strings.Join(parts, ", ")
+}
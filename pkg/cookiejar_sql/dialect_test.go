@@ -0,0 +1,13 @@
+package cookiejar_sql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialect(t *testing.T) {
+	assert.Equal(t, "?, ?, ?", DialectSQLite.placeholders(1, 3))
+	assert.Equal(t, "$2, $3, $4", DialectPostgreSQL.placeholders(2, 3))
+	assert.Equal(t, "PostgreSQL", DialectPostgreSQL.String())
+}
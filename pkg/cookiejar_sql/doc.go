@@ -0,0 +1,6 @@
+// Package cookiejar_sql stores entries in a database/sql database,
+// so multiple processes can share one cookie store.
+//
+// The caller opens the database with a driver of its choice,
+// SQLite and PostgreSQL dialects are supported.
+package cookiejar_sql
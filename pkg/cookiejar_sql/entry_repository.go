@@ -0,0 +1,307 @@
+package cookiejar_sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+)
+
+var endOfTime = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+
+type EntryRepository interface {
+	cookiejar.EntryRepository
+	cookiejar.EntryLister
+	cookiejar.EntryToucher
+}
+
+type entryRepository struct {
+	db      *sql.DB
+	dialect Dialect
+	table   string
+}
+
+const columns = `id, jar_key, name, value, domain, path, same_site, secure, http_only, persistent, host_only, partition_key, expires, creation, last_access, entry_order`
+
+func fromMicro(v int64) time.Time {
+	return time.UnixMicro(v).UTC()
+}
+
+func scanEntry(rows *sql.Rows) (_ *cookiejar.Entry, err error) {
+	var (
+		id           string
+		key          string
+		name         string
+		value        string
+		domain       string
+		path         string
+		sameSite     string
+		secure       bool
+		httpOnly     bool
+		persistent   bool
+		hostOnly     bool
+		partitionKey string
+		expires      sql.NullInt64
+		creation     int64
+		lastAccess   int64
+		order        int
+	)
+	err = rows.Scan(
+		&id,
+		&key,
+		&name,
+		&value,
+		&domain,
+		&path,
+		&sameSite,
+		&secure,
+		&httpOnly,
+		&persistent,
+		&hostOnly,
+		&partitionKey,
+		&expires,
+		&creation,
+		&lastAccess,
+		&order,
+	)
+	if err != nil {
+		return
+	}
+	var expiresTime = endOfTime
+	if expires.Valid {
+		expiresTime = fromMicro(expires.Int64)
+	}
+	return cookiejar.EntryFromRepository(
+		key,
+		name,
+		value,
+		domain,
+		path,
+		sameSite,
+		secure,
+		httpOnly,
+		persistent,
+		hostOnly,
+		expiresTime,
+		fromMicro(creation),
+		order,
+		cookiejar.EntryOptionPartitionKey(partitionKey),
+		cookiejar.EntryOptionLastAccess(fromMicro(lastAccess)),
+	)
+}
+
+func (r *entryRepository) query(ctx context.Context, cb func(i cookiejar.Entry) (err error), query string, args ...any) (err error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return err
+		}
+		err = cb(*e)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Find implements EntryRepository
+func (r *entryRepository) Find(ctx context.Context, key string) cookiejar.EntryIterator {
+	return cookiejar.EntryIteratorFunc(func(cb func(i cookiejar.Entry) (err error)) (err error) {
+		defer func() {
+			if err != nil {
+				err = fmt.Errorf("cookiejar_sql: entryRepository.Find('%s'): %w", key, err)
+			}
+		}()
+		return r.query(
+			ctx,
+			cb,
+			`SELECT `+columns+` FROM `+r.table+` WHERE jar_key = `+r.dialect.placeholder(1),
+			key,
+		)
+	})
+}
+
+// FindAll implements EntryLister
+func (r *entryRepository) FindAll(ctx context.Context) cookiejar.EntryIterator {
+	return cookiejar.EntryIteratorFunc(func(cb func(i cookiejar.Entry) (err error)) (err error) {
+		defer func() {
+			if err != nil {
+				err = fmt.Errorf("cookiejar_sql: entryRepository.FindAll: %w", err)
+			}
+		}()
+		return r.query(ctx, cb, `SELECT `+columns+` FROM `+r.table)
+	})
+}
+
+// execMany executes query with each args in one transaction.
+func (r *entryRepository) execMany(ctx context.Context, query string, args [][]any) (err error) {
+	if len(args) == 0 {
+		return
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return
+	}
+	defer stmt.Close()
+	for _, i := range args {
+		_, err = stmt.ExecContext(ctx, i...)
+		if err != nil {
+			return
+		}
+	}
+	return tx.Commit()
+}
+
+// Delete implements EntryRepository
+func (r *entryRepository) Delete(ctx context.Context, id string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_sql: entryRepository.Delete('%s'): %w", id, err)
+		}
+	}()
+	_, err = r.db.ExecContext(ctx, `DELETE FROM `+r.table+` WHERE id = `+r.dialect.placeholder(1), id)
+	return
+}
+
+// DeleteMany implements EntryRepository
+func (r *entryRepository) DeleteMany(ctx context.Context, id []string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_sql: entryRepository.DeleteMany(%s): %w", id, err)
+		}
+	}()
+	var args = make([][]any, 0, len(id))
+	for _, i := range id {
+		args = append(args, []any{i})
+	}
+	return r.execMany(ctx, `DELETE FROM `+r.table+` WHERE id = `+r.dialect.placeholder(1), args)
+}
+
+// Touch implements EntryToucher
+func (r *entryRepository) Touch(ctx context.Context, id []string, t time.Time) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_sql: entryRepository.Touch(%s): %w", id, err)
+		}
+	}()
+	var args = make([][]any, 0, len(id))
+	for _, i := range id {
+		args = append(args, []any{t.UnixMicro(), i})
+	}
+	return r.execMany(
+		ctx,
+		`UPDATE `+r.table+` SET last_access = `+r.dialect.placeholder(1)+` WHERE id = `+r.dialect.placeholder(2),
+		args,
+	)
+}
+
+// Save implements EntryRepository,
+// creation and order of existing row are kept by the upsert.
+func (r *entryRepository) Save(ctx context.Context, entry cookiejar.Entry) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("cookiejar_sql: entryRepository.Save: %w", err)
+		}
+	}()
+	var expires sql.NullInt64
+	if v := entry.Expires(); !v.IsZero() && !v.Equal(endOfTime) {
+		expires = sql.NullInt64{Int64: v.UnixMicro(), Valid: true}
+	}
+	_, err = r.db.ExecContext(
+		ctx,
+		`INSERT INTO `+r.table+` (`+columns+`) VALUES (`+r.dialect.placeholders(1, 16)+`)
+ON CONFLICT (id) DO UPDATE SET
+	value = excluded.value,
+	same_site = excluded.same_site,
+	secure = excluded.secure,
+	http_only = excluded.http_only,
+	persistent = excluded.persistent,
+	host_only = excluded.host_only,
+	expires = excluded.expires,
+	last_access = excluded.last_access`,
+		entry.ID(),
+		entry.Key(),
+		entry.Name(),
+		entry.Value(),
+		entry.Domain(),
+		entry.Path(),
+		entry.SameSite(),
+		entry.Secure(),
+		entry.HttpOnly(),
+		entry.Persistent(),
+		entry.HostOnly(),
+		entry.PartitionKey(),
+		expires,
+		entry.Creation().UnixMicro(),
+		entry.LastAccess().UnixMicro(),
+		entry.Order(),
+	)
+	return
+}
+
+// Options are the options for creating a new EntryRepository.
+type Options struct {
+	dialect Dialect
+	table   string
+}
+
+type Option func(opts *Options)
+
+// OptionDialect defines SQL dialect of the database,
+// defaults to DialectSQLite.
+func OptionDialect(v Dialect) Option {
+	return func(opts *Options) {
+		opts.dialect = v
+	}
+}
+
+// OptionTable defines table name, migration versions are stored in
+// `<table>_migrations` table. Defaults to `cookiejar_entries`.
+func OptionTable(v string) Option {
+	if v == "" {
+		panic("empty table name")
+	}
+	return func(opts *Options) {
+		opts.table = v
+	}
+}
+
+func newOptions(options ...Option) *Options {
+	var opts = new(Options)
+	opts.dialect = DialectSQLite
+	opts.table = "cookiejar_entries"
+	for _, i := range options {
+		i(opts)
+	}
+	return opts
+}
+
+// NewEntryRepository use db to store cookies,
+// pending schema migrations are applied before return.
+func NewEntryRepository(ctx context.Context, db *sql.DB, options ...Option) (_ EntryRepository, err error) {
+	if db == nil {
+		panic("nil db")
+	}
+	var opts = newOptions(options...)
+	err = migrate(ctx, db, opts.dialect, opts.table)
+	if err != nil {
+		return nil, fmt.Errorf("cookiejar_sql: NewEntryRepository: %w", err)
+	}
+	return &entryRepository{
+		db:      db,
+		dialect: opts.dialect,
+		table:   opts.table,
+	}, nil
+}
@@ -0,0 +1,133 @@
+package cookiejar_sql
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NateScarlet/cookiejar/internal/test_util"
+	"github.com/NateScarlet/cookiejar/pkg/cookiejar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func TestEntryRepository(t *testing.T) {
+	var ctx = context.Background()
+	url1, _ := url.Parse("http://example.com")
+	var useDatabase = func(t *testing.T) *sql.DB {
+		db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "cookies.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	var useJar = func(t *testing.T, db *sql.DB) (cookiejar.Jar, EntryRepository, *test_util.Clock) {
+		repo, err := NewEntryRepository(ctx, db)
+		require.NoError(t, err)
+		var clock = test_util.NewClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+		jar, err := cookiejar.New(ctx, cookiejar.OptionEntryRepository(repo), cookiejar.OptionClock(clock))
+		require.NoError(t, err)
+		return jar, repo, clock
+	}
+
+	t.Run("should able to save", func(t *testing.T) {
+		var jar, _, _ = useJar(t, useDatabase(t))
+		jar.SetCookies(url1, []*http.Cookie{
+			{Name: "a", Value: "1", Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode},
+		})
+		var cookies = jar.Cookies(url1)
+		require.Len(t, cookies, 1)
+		assert.Equal(t, "1", cookies[0].Value)
+	})
+
+	t.Run("should able to delete", func(t *testing.T) {
+		var jar, _, clock = useJar(t, useDatabase(t))
+		jar.SetCookies(url1, []*http.Cookie{
+			{Name: "a", Value: "1", Path: "/", Expires: clock.Now().Add(time.Second)},
+		})
+		assert.Len(t, jar.Cookies(url1), 1)
+		clock.Add(time.Second + 1)
+		assert.Len(t, jar.Cookies(url1), 0)
+		all, err := jar.All()
+		require.NoError(t, err)
+		assert.Len(t, all, 0)
+	})
+
+	t.Run("should keep creation and order", func(t *testing.T) {
+		var jar, repo, clock = useJar(t, useDatabase(t))
+		jar.SetCookies(url1, []*http.Cookie{
+			{Name: "a", Value: "1"},
+			{Name: "b", Value: "2"},
+		})
+		var created = clock.Now()
+		clock.Add(time.Minute)
+		jar.SetCookies(url1, []*http.Cookie{
+			{Name: "b", Value: "3", MaxAge: 3600},
+		})
+		var entries = make(map[string]cookiejar.Entry)
+		require.NoError(t, repo.Find(ctx, "example.com").ForEach(func(i cookiejar.Entry) (err error) {
+			entries[i.Name()] = i
+			return
+		}))
+		require.Len(t, entries, 2)
+		assert.Equal(t, "3", entries["b"].Value())
+		assert.Equal(t, created, entries["b"].Creation())
+		assert.Equal(t, 1, entries["b"].Order())
+		assert.Equal(t, clock.Now(), entries["b"].LastAccess())
+		assert.True(t, entries["b"].Persistent())
+		assert.Equal(t, clock.Now().Add(time.Hour), entries["b"].Expires())
+	})
+
+	t.Run("should touch", func(t *testing.T) {
+		var jar, repo, clock = useJar(t, useDatabase(t))
+		jar.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		clock.Add(time.Hour)
+		jar.Cookies(url1)
+		require.NoError(t, repo.Find(ctx, "example.com").ForEach(func(i cookiejar.Entry) (err error) {
+			assert.Equal(t, clock.Now(), i.LastAccess())
+			return
+		}))
+	})
+
+	t.Run("should share between repositories", func(t *testing.T) {
+		var db = useDatabase(t)
+		var jar1, _, _ = useJar(t, db)
+		var jar2, _, _ = useJar(t, db)
+		jar1.SetCookies(url1, []*http.Cookie{{Name: "a", Value: "1"}})
+		assert.Len(t, jar2.Cookies(url1), 1)
+		require.NoError(t, jar2.RemoveDomain("example.com"))
+		assert.Len(t, jar1.Cookies(url1), 0)
+	})
+
+	t.Run("should keep partitions separate", func(t *testing.T) {
+		var jar, _, _ = useJar(t, useDatabase(t))
+		var u, _ = url.Parse("https://widget.example.com")
+		for _, site := range []string{"https://example.org", "https://example.net"} {
+			topLevelSite, _ := url.Parse(site)
+			jar.SetCookiesForRequest(u, cookiejar.RequestContext{TopLevelSite: topLevelSite}, []*http.Cookie{
+				{Name: "a", Value: site, Secure: true, Partitioned: true},
+			})
+		}
+		all, err := jar.All()
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+	})
+}
+
+func TestMigrate(t *testing.T) {
+	var ctx = context.Background()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "cookies.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	for i := 0; i < 2; i++ {
+		_, err = NewEntryRepository(ctx, db, OptionTable("cookies"))
+		require.NoError(t, err)
+	}
+	var version int
+	require.NoError(t, db.QueryRow("SELECT MAX(version) FROM cookies_migrations").Scan(&version))
+	assert.Equal(t, migrations[len(migrations)-1].version, version)
+}
@@ -0,0 +1,96 @@
+package cookiejar_sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type migration struct {
+	version int
+	up      func(d Dialect, table string) []string
+}
+
+// migrations must be ordered by version, statements should be idempotent
+// since multiple processes may migrate at the same time.
+var migrations = []migration{
+	{
+		version: 1,
+		up: func(d Dialect, table string) []string {
+			return []string{
+				`CREATE TABLE IF NOT EXISTS ` + table + ` (
+	id TEXT NOT NULL PRIMARY KEY,
+	jar_key TEXT NOT NULL,
+	name TEXT NOT NULL,
+	value TEXT NOT NULL,
+	domain TEXT NOT NULL,
+	path TEXT NOT NULL,
+	same_site TEXT NOT NULL,
+	secure BOOLEAN NOT NULL,
+	http_only BOOLEAN NOT NULL,
+	persistent BOOLEAN NOT NULL,
+	host_only BOOLEAN NOT NULL,
+	partition_key TEXT NOT NULL,
+	expires BIGINT,
+	creation BIGINT NOT NULL,
+	last_access BIGINT NOT NULL,
+	entry_order INTEGER NOT NULL
+)`,
+				`CREATE INDEX IF NOT EXISTS ` + table + `_jar_key ON ` + table + ` (jar_key)`,
+			}
+		},
+	},
+}
+
+// migrate applies pending migrations and records applied versions
+// in `<table>_migrations` table.
+func migrate(ctx context.Context, db *sql.DB, d Dialect, table string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("migrate: %w", err)
+		}
+	}()
+	var migrationTable = table + "_migrations"
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+migrationTable+` (
+	version INTEGER NOT NULL PRIMARY KEY
+)`)
+	if err != nil {
+		return
+	}
+	var current int
+	err = db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM `+migrationTable).Scan(&current)
+	if err != nil {
+		return
+	}
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		err = func() (err error) {
+			tx, err := db.BeginTx(ctx, nil)
+			if err != nil {
+				return
+			}
+			defer tx.Rollback()
+			for _, stmt := range m.up(d, table) {
+				_, err = tx.ExecContext(ctx, stmt)
+				if err != nil {
+					return
+				}
+			}
+			_, err = tx.ExecContext(
+				ctx,
+				`INSERT INTO `+migrationTable+` (version) VALUES (`+d.placeholder(1)+`) ON CONFLICT (version) DO NOTHING`,
+				m.version,
+			)
+			if err != nil {
+				return
+			}
+			return tx.Commit()
+		}()
+		if err != nil {
+			return fmt.Errorf("version %d: %w", m.version, err)
+		}
+	}
+	return
+}